@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+
+	"show-live/config"
+	"show-live/internal/showstart"
+	"show-live/internal/simullink"
+	"show-live/internal/source"
+	"show-live/internal/zhengzai"
+	"show-live/pkg/db"
+	"show-live/pkg/http"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+const (
+	responsesDir = "responses"
+	goldenFile   = "golden.json"
+	gotFile      = "golden.got.json"
+)
+
+// fixture 录制各平台的真实响应，并离线检查解析结果是否与录制时相同：
+// fixture record 请求各平台，将响应保存到 <dir>/<平台>/testdata/responses，解析出的活动保存到 <dir>/<平台>/testdata/golden.json，
+// 各平台的测试会回放这些响应；
+// fixture check 不发出请求，回放录制的响应并重新解析，与 golden.json 不同时保存到 golden.got.json 并以非0状态退出
+func main() {
+	var config config.Daemon
+	configFilePath := flag.String("config", "config-fixture.yml", "config file")
+	dir := flag.String("dir", "../../internal", "fixture dir")
+	flag.Parse()
+	configFile, err := os.ReadFile(*configFilePath)
+	if err != nil {
+		log.Logger.Fatal(err)
+	}
+	if err := yaml.Unmarshal(configFile, &config); err != nil {
+		log.Logger.Fatal(err)
+	}
+	log.InitLogger(config.Log.LogSuffix, config.Log.LogDir)
+	if flag.NArg() != 1 || (flag.Arg(0) != "record" && flag.Arg(0) != "check") {
+		fmt.Println("用法：fixture -config config-fixture.yml -dir ../../internal record|check")
+		os.Exit(2)
+	}
+	names := sourceNames(config)
+	if len(names) == 0 {
+		log.Logger.Fatal("没有配置任何平台")
+	}
+	failed := false
+	for _, name := range names {
+		var err error
+		if flag.Arg(0) == "record" {
+			err = record(config, name, filepath.Join(*dir, name, "testdata"))
+		} else {
+			err = check(config, name, filepath.Join(*dir, name, "testdata"))
+		}
+		if err != nil {
+			log.Logger.Errorf("%s：%v", name, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func sourceNames(conf config.Daemon) []string {
+	names := make([]string, 0)
+	if conf.ShowStart != nil {
+		names = append(names, "showstart")
+	}
+	if conf.Simullink != nil {
+		names = append(names, "simullink")
+	}
+	if conf.Zhengzai != nil {
+		names = append(names, "zhengzai")
+	}
+	return names
+}
+
+// newSource 创建平台，使用临时的空数据库，这样所有活动都会被当作新活动返回
+func newSource(conf config.Daemon, name string, d db.DB) source.Source {
+	switch name {
+	case "showstart":
+		c := *conf.ShowStart
+		// 按活动ID查找和重新检查的结果依赖数据库中的状态，不参与检查
+		c.MaxNotFoundCount = 0
+		c.Max404CountToCheck = 0
+		c.TrackChanges = false
+		return showstart.NewShowStartGeterWithConfig(d, c)
+	case "simullink":
+		return simullink.NewSimullinkGetter(d, conf.Simullink.URL, conf.Simullink.CityCode)
+	default:
+		return zhengzai.NewZhengZaiGetterGetter(d, conf.Zhengzai.URL, conf.Zhengzai.AdCode)
+	}
+}
+
+// fetch 按 httpConf 请求平台并返回解析出的活动
+func fetch(conf config.Daemon, name string, httpConf config.HTTP) ([]byte, error) {
+	if err := http.Configure(httpConf); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "fixture-"+name)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	d, err := db.InitCache(tmp)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Exit()
+	events, err := newSource(conf, name, d).GetEventsToNotify()
+	if err != nil {
+		return nil, fmt.Errorf("获取活动出错 %v", err)
+	}
+	return json.MarshalIndent(events, "", "  ")
+}
+
+func record(conf config.Daemon, name, dir string) error {
+	responses := filepath.Join(dir, responsesDir)
+	if err := os.RemoveAll(responses); err != nil {
+		return err
+	}
+	httpConf := conf.HTTP
+	httpConf.Record = responses
+	golden, err := fetch(conf, name, httpConf)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, goldenFile), golden, 0644); err != nil {
+		return err
+	}
+	log.Logger.Infof("%s 的响应已录制到 %s", name, dir)
+	return nil
+}
+
+func check(conf config.Daemon, name, dir string) error {
+	want, err := os.ReadFile(filepath.Join(dir, goldenFile))
+	if err != nil {
+		return fmt.Errorf("读取 %s 出错 %v，需要先运行 fixture record", goldenFile, err)
+	}
+	// 回放时不需要限速和代理
+	httpConf := config.HTTP{Rate: 1000, Replay: filepath.Join(dir, responsesDir)}
+	got, err := fetch(conf, name, httpConf)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+		os.Remove(filepath.Join(dir, gotFile))
+		log.Logger.Infof("%s 的解析结果与录制时相同", name)
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(dir, gotFile), got, 0644); err != nil {
+		return err
+	}
+	return fmt.Errorf("解析结果与 %s 不同，%s，本次结果已保存到 %s", goldenFile, diff(want, got), filepath.Join(dir, gotFile))
+}
+
+// diff 按活动的键比较两次的解析结果
+func diff(want, got []byte) string {
+	var wantEvents, gotEvents []*utils.Event
+	if err := json.Unmarshal(want, &wantEvents); err != nil {
+		return fmt.Sprintf("%s 格式错误 %v", goldenFile, err)
+	}
+	json.Unmarshal(got, &gotEvents)
+	wantByKey := make(map[string][]byte)
+	for _, e := range wantEvents {
+		wantByKey[e.Key()], _ = json.Marshal(e)
+	}
+	var missing, added, changed int
+	for _, e := range gotEvents {
+		v, _ := json.Marshal(e)
+		w, ok := wantByKey[e.Key()]
+		switch {
+		case !ok:
+			added++
+		case !bytes.Equal(v, w):
+			changed++
+		}
+		delete(wantByKey, e.Key())
+	}
+	missing = len(wantByKey)
+	if missing == 0 && added == 0 && changed == 0 {
+		return "活动的顺序不同"
+	}
+	return fmt.Sprintf("少了 %d 个活动，多了 %d 个活动，%d 个活动的内容不同", missing, added, changed)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"gopkg.in/yaml.v2"
+
+	"show-live/config"
+	"show-live/internal/daemon"
+	"show-live/pkg/log"
+)
+
+func main() {
+	var config config.Daemon
+	configFilePath := flag.String("config", "config-show-live.yml", "config file")
+	flag.Parse()
+	configFile, err := os.ReadFile(*configFilePath)
+	if err != nil {
+		log.Logger.Fatal(err)
+	}
+	if err := yaml.Unmarshal(configFile, &config); err != nil {
+		log.Logger.Fatal(err)
+	}
+	log.InitLogger(config.Log.LogSuffix, config.Log.LogDir)
+	d, err := daemon.New(config)
+	if err != nil {
+		log.Logger.Error(err)
+		return
+	}
+	if flag.NArg() > 0 {
+		command(d, flag.Args())
+		return
+	}
+	log.Logger.Info("服务准备运行，启动中.........")
+	if err := d.Start(); err != nil {
+		log.Logger.Error(err)
+		d.Stop()
+		return
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	log.Logger.Info("收到退出信号，等待正在运行的任务结束.........")
+	if err := d.Stop(); err != nil {
+		log.Logger.Errorf("数据库退出过程中出错 %v", err)
+	}
+}
+
+// command 执行命令行中的命令，如 show-live going showstart 123456 将秀动的活动 123456 标记为要去
+func command(d *daemon.Daemon, args []string) {
+	defer func() {
+		if err := d.Stop(); err != nil {
+			log.Logger.Errorf("数据库退出过程中出错 %v", err)
+		}
+	}()
+	switch args[0] {
+	case "going":
+		if len(args) != 3 {
+			fmt.Println("用法：show-live going <平台> <活动ID>，如 show-live going showstart 123456")
+			return
+		}
+		e, err := d.MarkGoing(args[1], args[2])
+		if err != nil {
+			log.Logger.Error(err)
+			return
+		}
+		log.Logger.Infof("已将活动 %s 标记为要去，演出时间：%s，场地：%s", e.Name, e.Time, e.Site)
+	default:
+		fmt.Printf("不支持的命令 %s\n", args[0])
+	}
+}
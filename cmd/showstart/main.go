@@ -2,26 +2,22 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"os"
-	"time"
 
 	"gopkg.in/yaml.v2"
 
 	"show-live/config"
+	"show-live/internal/filter"
+	"show-live/internal/pipeline"
 	"show-live/internal/showstart"
+	"show-live/internal/watchlist"
 	"show-live/pkg/db"
-	"show-live/pkg/email"
+	"show-live/pkg/http"
 	"show-live/pkg/log"
-	"show-live/utils"
-)
-
-const (
-	emailTryTimes = 10
+	"show-live/pkg/notifier"
 )
 
 func main() {
-	startTime := time.Now()
 	var config config.ShowStart
 	configFilePath := flag.String("config", "config-showstart.yml", "config file")
 	if configFilePath != nil {
@@ -36,6 +32,10 @@ func main() {
 	}
 	log.InitLogger(config.Log.LogSuffix, config.Log.LogDir)
 	log.Logger.Info("服务准备运行，启动中.........")
+	if err := http.Configure(config.HTTP); err != nil {
+		log.Logger.Errorf("http 配置错误 %v", err)
+		return
+	}
 	d, err := db.InitSqlite(config.DBFile)
 	if err != nil {
 		log.Logger.Errorf("初始化数据库错误 %v", err)
@@ -47,58 +47,32 @@ func main() {
 		}
 	}()
 
-	c := showstart.NewShowStartGeter(d, config.TagsSelected, config.CityCode)
-	e := email.NewEmailSender(config.Email)
-	events, err := c.GetEventsToNotify()
+	c := showstart.NewShowStartGeterWithConfig(d, config.ShowStartSource)
+	notifiers, err := notifier.NewAll(config.Notifiers, config.Email)
 	if err != nil {
-		log.Logger.Errorf("get events to notify error %v", err)
-		if err := trySendEmail(e, "秀动获取最新演出出错了", err.Error()); err != nil {
-			log.Logger.Errorf("发送邮件失败 %v", err)
-		}
+		log.Logger.Error(err)
 		return
 	}
-	endTime := time.Now()
-	if len(events) == 0 {
-		log.Logger.Info("没有活动需要通知.........")
+	f, err := filter.New(config.TagsSelected, showstart.Filter(config.Filter))
+	if err != nil {
+		log.Logger.Errorf("过滤规则错误 %v", err)
+		return
 	}
-	cont := content(startTime, endTime, events)
-	log.Logger.Infof("准备通知，通知内容为: %s", cont)
-	if err := trySendEmail(e, fmt.Sprintf("秀动上新了%d个演出", len(events)), cont); err == nil {
-		log.Logger.Infof("成功通知了 %d 个活动........", len(events))
-	} else {
-		log.Logger.Infof("通知活动时出错：%v", err)
+	p := pipeline.New(notifiers)
+	p.SetFilter(c.Name(), f)
+	p.Watchlist = watchlist.New(config.Watchlist)
+	p.Outbox = d
+	p.Snapshots = d
+	p.Runs = d
+	if config.SaveCover {
+		p.CoverDir = config.CoverDir
 	}
-}
-
-func trySendEmail(e *email.EmailSender, title string, content string) error {
-	var errToReturn error
-	for i := 0; i < emailTryTimes; i++ {
-		err := e.Send(title, content)
-		if err == nil {
-			break
-		}
-		if err != nil {
-			log.Logger.Errorf("发送邮件失败 %v, 第 %d 次失败", err, i+1)
-		}
-		if i == emailTryTimes-1 {
-			errToReturn = err
-		}
-		time.Sleep(time.Second)
-	}
-	return errToReturn
-}
-
-func content(start, end time.Time, events []*utils.Event) string {
-	time := fmt.Sprintf("<p>开始运行时间：%s，结束时间：%s</p>", start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"))
-	if len(events) == 0 {
-		return time + "<p>没有活动需要通知</p>"
+	if err := p.SetReminders(d, config.Reminder); err != nil {
+		log.Logger.Error(err)
+		return
 	}
-	r := fmt.Sprintf("%s<p>购票前务必先看大麦与确认是否有空观看，即使显示独家也要确认大麦！</p>", time)
-	for _, e := range events {
-		r += fmt.Sprintf("<p>🌈<a href=\"%s\"><font color=green></strong>%s<strong></font></a>，<strong>演出时间</strong>：%s，"+
-			"<strong>艺人</strong>： %s，<strong>场地</strong><font color=Tomato>：%s</font>，<strong>票价</strong>：%s，<a href=\"%s\">App内查看详情</a></p>",
-			e.WebURL, e.Name, e.Time, e.Artist, e.Site, e.Price, e.WebViewURL,
-		)
+	p.Run(c, d)
+	if err := p.SendReminders(); err != nil {
+		log.Logger.Errorf("发送提醒出错 %v", err)
 	}
-	return r
 }
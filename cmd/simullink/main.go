@@ -7,10 +7,14 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"show-live/config"
+	"show-live/internal/filter"
+	"show-live/internal/pipeline"
 	"show-live/internal/simullink"
+	"show-live/internal/watchlist"
 	"show-live/pkg/db"
-	"show-live/pkg/email"
+	"show-live/pkg/http"
 	"show-live/pkg/log"
+	"show-live/pkg/notifier"
 )
 
 func main() {
@@ -27,6 +31,10 @@ func main() {
 		}
 	}
 	log.InitLogger(config.Log.LogSuffix, config.Log.LogDir)
+	if err := http.Configure(config.HTTP); err != nil {
+		log.Logger.Errorf("http 配置错误 %v", err)
+		return
+	}
 	d, err := db.InitCache(config.DBDir)
 	if err != nil {
 		log.Logger.Errorf("init cache error %v", err)
@@ -37,26 +45,22 @@ func main() {
 			log.Logger.Errorf("db exits error %v", err)
 		}
 	}()
-	e := email.NewEmailSender(config.Email)
-	c := simullink.NewSimullinkGetter(d, config.TagsSelected, config.URL, config.CityCode)
-	events, err := c.GetEventsToNotify()
+	notifiers, err := notifier.NewAll(config.Notifiers, config.Email)
 	if err != nil {
-		log.Logger.Errorf("get events to notify error %v", err)
-		if err := e.Send("同感获取最新演出出错了", err.Error()); err != nil {
-			log.Logger.Errorf("send email error %v", err)
-		}
+		log.Logger.Error(err)
 		return
 	}
-	if len(events) == 0 {
-		log.Logger.Info("no new event to send")
+	c := simullink.NewSimullinkGetter(d, config.URL, config.CityCode)
+	f, err := filter.New(config.TagsSelected, config.Filter)
+	if err != nil {
+		log.Logger.Errorf("过滤规则错误 %v", err)
 		return
 	}
-	var content string
-	for _, v := range events {
-		content += v + "\n"
-	}
-	if err := e.Send("同感演出上新了", content); err != nil {
-		log.Logger.Errorf("send email error %v", err)
+	p := pipeline.New(notifiers)
+	p.SetFilter(c.Name(), f)
+	p.Watchlist = watchlist.New(config.Watchlist)
+	if config.SaveCover {
+		p.CoverDir = config.CoverDir
 	}
-	log.Logger.Infof("%d event sent", len(events))
+	p.Run(c, d)
 }
@@ -0,0 +1,62 @@
+package action
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+// 通知邮件中可以点击的操作
+const (
+	NotInterested = "not_interested"
+	Going         = "going"
+	MuteArtist    = "mute_artist"
+	MuteVenue     = "mute_venue"
+)
+
+// Path 处理操作链接的路径
+const Path = "/action"
+
+// Names 操作的名称
+var Names = map[string]string{
+	NotInterested: "不感兴趣",
+	Going:         "要去",
+	MuteArtist:    "屏蔽艺人",
+	MuteVenue:     "屏蔽场地",
+}
+
+// Signer 生成和校验带签名的操作链接，链接中的参数被修改后签名会校验失败
+type Signer struct {
+	baseURL string
+	secret  []byte
+}
+
+// NewSigner baseURL 为局域网服务器的地址，如 http://192.168.1.2:8080
+func NewSigner(baseURL, secret string) *Signer {
+	return &Signer{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  []byte(secret),
+	}
+}
+
+// URL 生成操作链接，value 为活动在数据库中的键、艺人或场地
+func (s *Signer) URL(action, value string) string {
+	v := url.Values{}
+	v.Set("a", action)
+	v.Set("v", value)
+	v.Set("sig", s.sign(action, value))
+	return s.baseURL + Path + "?" + v.Encode()
+}
+
+// Verify 校验操作链接的签名
+func (s *Signer) Verify(action, value, sig string) bool {
+	return hmac.Equal([]byte(s.sign(action, value)), []byte(sig))
+}
+
+func (s *Signer) sign(action, value string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(action + "\n" + value))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
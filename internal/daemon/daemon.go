@@ -0,0 +1,269 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"show-live/config"
+	"show-live/internal/action"
+	"show-live/internal/filter"
+	"show-live/internal/pipeline"
+	"show-live/internal/server"
+	"show-live/internal/showstart"
+	"show-live/internal/simullink"
+	"show-live/internal/source"
+	"show-live/internal/watchlist"
+	"show-live/internal/zhengzai"
+	"show-live/pkg/atom"
+	"show-live/pkg/db"
+	"show-live/pkg/http"
+	"show-live/pkg/log"
+	"show-live/pkg/notifier"
+)
+
+const (
+	defaultSchedule = "30m"
+	// reminderSchedule 检查并发送到期提醒的周期
+	reminderSchedule = time.Minute
+)
+
+// registered 已注册的平台以及它使用的数据库
+type registered struct {
+	s source.Source
+	d db.DB
+}
+
+// Daemon 常驻运行，按各平台配置的周期获取新活动，数据库和通知渠道在多次运行之间保持打开
+type Daemon struct {
+	conf config.Daemon
+	d    db.DB
+	// dbs 各平台单独配置的数据库，相同配置的平台共用一个
+	dbs     map[config.DB]db.DB
+	p       *pipeline.Pipeline
+	cron    *cron.Cron
+	sources []registered
+	// server HTTP 服务，没有配置监听地址时为空
+	server *server.Server
+	// runLock 保证同一时间只有一个平台在运行，避免多个平台同时写数据库
+	runLock sync.Mutex
+}
+
+func New(conf config.Daemon) (*Daemon, error) {
+	if err := http.Configure(conf.HTTP); err != nil {
+		return nil, fmt.Errorf("http 配置错误 %v", err)
+	}
+	notifiers, err := notifier.NewAll(conf.Notifiers, conf.Email)
+	if err != nil {
+		return nil, err
+	}
+	d, err := db.InitSqlite(conf.DBFile)
+	if err != nil {
+		return nil, fmt.Errorf("初始化数据库错误 %v", err)
+	}
+	p := pipeline.New(notifiers)
+	p.Outbox = d
+	p.Snapshots = d
+	p.Mutes = d
+	p.Runs = d
+	if conf.Server.BaseURL != "" && conf.Server.Secret != "" {
+		p.Actions = action.NewSigner(conf.Server.BaseURL, conf.Server.Secret)
+	}
+	p.Watchlist = watchlist.New(conf.Watchlist)
+	if err := p.SetReminders(d, conf.Reminder); err != nil {
+		return nil, err
+	}
+	if conf.SaveCover {
+		p.CoverDir = conf.CoverDir
+	}
+	return &Daemon{
+		conf: conf,
+		d:    d,
+		dbs:  map[config.DB]db.DB{},
+		p:    p,
+		cron: cron.New(cron.WithChain(cron.Recover(cronLogger{}), cron.SkipIfStillRunning(cronLogger{}))),
+	}, nil
+}
+
+// Start 注册所有已配置的平台并立即运行一次，之后按各自的周期运行
+func (d *Daemon) Start() error {
+	if err := d.register(); err != nil {
+		return err
+	}
+	d.cron.Schedule(cron.Every(reminderSchedule), cron.FuncJob(d.sendReminders))
+	if d.conf.Server.Addr != "" {
+		d.server = server.New(d.conf.Server.Addr, d, d.p.Actions)
+		if err := d.server.Start(); err != nil {
+			return fmt.Errorf("启动 HTTP 服务出错 %v", err)
+		}
+	}
+	go func() {
+		for _, r := range d.sources {
+			d.run(r)
+		}
+	}()
+	d.cron.Start()
+	return nil
+}
+
+// register 注册所有已配置的平台
+func (d *Daemon) register() error {
+	if c := d.conf.ShowStart; c != nil {
+		sd, err := d.db(c.DB)
+		if err != nil {
+			return err
+		}
+		f, err := filter.New(c.TagsSelected, d.conf.Filter, showstart.Filter(c.Filter))
+		if err != nil {
+			return fmt.Errorf("秀动的过滤规则错误 %v", err)
+		}
+		if err := d.add(c.Schedule, showstart.NewShowStartGeterWithConfig(sd, *c), sd, f); err != nil {
+			return err
+		}
+	}
+	if c := d.conf.Simullink; c != nil {
+		sd, err := d.db(c.DB)
+		if err != nil {
+			return err
+		}
+		f, err := filter.New(c.TagsSelected, d.conf.Filter, c.Filter)
+		if err != nil {
+			return fmt.Errorf("同感的过滤规则错误 %v", err)
+		}
+		if err := d.add(c.Schedule, simullink.NewSimullinkGetter(sd, c.URL, c.CityCode), sd, f); err != nil {
+			return err
+		}
+	}
+	if c := d.conf.Zhengzai; c != nil {
+		sd, err := d.db(c.DB)
+		if err != nil {
+			return err
+		}
+		f, err := filter.New(nil, d.conf.Filter, c.Filter)
+		if err != nil {
+			return fmt.Errorf("正在现场的过滤规则错误 %v", err)
+		}
+		if err := d.add(c.Schedule, zhengzai.NewZhengZaiGetterGetter(sd, c.URL, c.AdCode), sd, f); err != nil {
+			return err
+		}
+	}
+	if len(d.sources) == 0 {
+		return fmt.Errorf("没有配置任何平台")
+	}
+	return nil
+}
+
+// db 返回平台使用的数据库，没有单独配置时使用守护进程的数据库
+func (d *Daemon) db(conf *config.DB) (db.DB, error) {
+	if conf == nil {
+		return d.d, nil
+	}
+	if sd, ok := d.dbs[*conf]; ok {
+		return sd, nil
+	}
+	sd, err := db.Init(*conf)
+	if err != nil {
+		return nil, fmt.Errorf("初始化数据库 %+v 错误 %v", *conf, err)
+	}
+	d.dbs[*conf] = sd
+	return sd, nil
+}
+
+func (d *Daemon) add(schedule string, s source.Source, sd db.DB, f *filter.Filter) error {
+	sched, err := parseSchedule(schedule)
+	if err != nil {
+		return fmt.Errorf("解析%s的运行周期 %s 出错 %v", s.DisplayName(), schedule, err)
+	}
+	d.p.SetFilter(s.Name(), f)
+	r := registered{s: s, d: sd}
+	d.cron.Schedule(sched, cron.FuncJob(func() { d.run(r) }))
+	d.sources = append(d.sources, r)
+	log.Logger.Infof("%s已注册，运行周期 %s", s.DisplayName(), schedule)
+	return nil
+}
+
+func (d *Daemon) run(r registered) {
+	d.runLock.Lock()
+	defer d.runLock.Unlock()
+	log.Rotate()
+	log.Logger.Infof("开始获取%s的最新演出.........", r.s.DisplayName())
+	d.p.Run(r.s, r.d)
+	if d.conf.Feed.File != "" {
+		if err := d.writeFeed(); err != nil {
+			log.Logger.Errorf("写入订阅文件 %s 出错 %v", d.conf.Feed.File, err)
+		}
+	}
+}
+
+// writeFeed 将最近发现的活动写入订阅文件，先写入临时文件再重命名，避免发布时读到写了一半的文件
+func (d *Daemon) writeFeed() error {
+	limit := d.conf.Feed.Limit
+	if limit <= 0 {
+		limit = server.DefaultFeedLimit
+	}
+	events, err := d.RecentEvents(limit)
+	if err != nil {
+		return err
+	}
+	b, err := atom.Feed(server.FeedTitle, d.conf.Feed.Link, events)
+	if err != nil {
+		return err
+	}
+	tmp := d.conf.Feed.File + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.conf.Feed.File)
+}
+
+// sendReminders 发送到期的提醒，与平台的运行互斥
+func (d *Daemon) sendReminders() {
+	d.runLock.Lock()
+	defer d.runLock.Unlock()
+	if err := d.p.SendReminders(); err != nil {
+		log.Logger.Errorf("发送提醒出错 %v", err)
+	}
+}
+
+// Stop 等待正在运行的任务结束后关闭数据库
+func (d *Daemon) Stop() error {
+	if d.server != nil {
+		if err := d.server.Stop(); err != nil {
+			log.Logger.Errorf("关闭 HTTP 服务出错 %v", err)
+		}
+	}
+	<-d.cron.Stop().Done()
+	for conf, sd := range d.dbs {
+		if err := sd.Exit(); err != nil {
+			log.Logger.Errorf("数据库 %+v 退出过程中出错 %v", conf, err)
+		}
+	}
+	return d.d.Exit()
+}
+
+// parseSchedule 解析运行周期，时间间隔（如 30m）优先，其次是标准的五段 cron 表达式
+func parseSchedule(s string) (cron.Schedule, error) {
+	if s == "" {
+		s = defaultSchedule
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("时间间隔必须大于0")
+		}
+		return cron.Every(d), nil
+	}
+	return cron.ParseStandard(s)
+}
+
+type cronLogger struct{}
+
+func (cronLogger) Info(msg string, keysAndValues ...interface{}) {
+	log.Logger.Infof("%s %v", msg, keysAndValues)
+}
+
+func (cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	log.Logger.Errorf("%s %v %v", msg, err, keysAndValues)
+}
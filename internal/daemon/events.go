@@ -0,0 +1,214 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+// Events 返回所有数据库中处于这些状态的活动，活动信息来自推送时保存的快照，没有快照的活动会被忽略
+func (d *Daemon) Events(statuses ...string) ([]*utils.Event, error) {
+	events := make([]*utils.Event, 0)
+	seen := make(map[string]bool)
+	for _, sd := range d.databases() {
+		for _, status := range statuses {
+			keys, err := sd.GetEventByValue(status)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range keys {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				e, err := d.snapshot(key)
+				if err != nil {
+					log.Logger.Error(err)
+					continue
+				}
+				if e != nil {
+					events = append(events, e)
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+// RecentEvents 返回最近发现的活动
+func (d *Daemon) RecentEvents(limit int) ([]*db.Discovered, error) {
+	return d.p.Snapshots.RecentEvents(limit)
+}
+
+// MarkGoing 将活动标记为要去，用于命令行中手动标记
+func (d *Daemon) MarkGoing(source, id string) (*utils.Event, error) {
+	key := utils.EventKey(source, id)
+	e, err := d.snapshot(key)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("没有找到活动 %s，只能标记已推送过的活动", key)
+	}
+	return e, d.SetStatus(key, e.Name, db.EventGoing)
+}
+
+// SetStatus 修改活动的状态，活动信息来自推送时保存的快照，标记为要去的活动必须有快照
+func (d *Daemon) SetStatus(key, name, status string) error {
+	source, id, ok := utils.SplitKey(key)
+	if !ok {
+		return fmt.Errorf("无效的活动 %s", key)
+	}
+	sd, err := d.sourceDB(source)
+	if err != nil {
+		return err
+	}
+	e, err := d.snapshot(key)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		if status == db.EventGoing {
+			return fmt.Errorf("没有找到活动 %s 的信息，无法添加演出提醒", key)
+		}
+		if name == "" {
+			name = "未知"
+		}
+		e = &utils.Event{Source: source, ID: id, Name: name}
+	}
+	return d.p.SetStatus(sd, e, status)
+}
+
+// Mute 屏蔽艺人或场地，之后的运行中这些艺人和场地的活动会被过滤掉
+func (d *Daemon) Mute(kind, value string) error {
+	return d.p.Mutes.Mute(kind, value)
+}
+
+// RecentRuns 返回最近的运行结果
+func (d *Daemon) RecentRuns(source string, limit int) ([]*db.Run, error) {
+	return d.p.Runs.RecentRuns(source, limit)
+}
+
+// QueryEvents 按条件查询所有数据库中的活动，活动的快照来自守护进程的数据库
+func (d *Daemon) QueryEvents(q db.EventQuery) ([]*db.EventRecord, int, error) {
+	dbs := d.databases()
+	if len(dbs) == 1 {
+		return d.queryEvents(dbs[0], q)
+	}
+	// 多个数据库时每个数据库都取到当前页为止的活动，合并排序后再分页
+	page := q
+	page.Offset = 0
+	if q.Limit > 0 {
+		page.Limit = q.Offset + q.Limit
+	}
+	records := make([]*db.EventRecord, 0)
+	total := 0
+	for _, sd := range dbs {
+		r, n, err := d.queryEvents(sd, page)
+		if err != nil {
+			return nil, 0, err
+		}
+		records = append(records, r...)
+		total += n
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].UpdatedAt.After(records[j].UpdatedAt)
+	})
+	if q.Offset >= len(records) {
+		return []*db.EventRecord{}, total, nil
+	}
+	records = records[q.Offset:]
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, total, nil
+}
+
+// queryEvents 查询单个数据库中的活动。快照保存在守护进程的数据库中，平台使用单独的数据库时
+// 先按不需要快照的条件查询，补上守护进程数据库中的快照后再过滤和分页
+func (d *Daemon) queryEvents(sd db.DB, q db.EventQuery) ([]*db.EventRecord, int, error) {
+	querier, ok := sd.(db.Querier)
+	if !ok {
+		return nil, 0, fmt.Errorf("数据库 %T 不支持查询活动，需要使用 sqlite", sd)
+	}
+	if sd == d.d {
+		return querier.QueryEvents(q)
+	}
+	all, _, err := querier.QueryEvents(q.Unfiltered())
+	if err != nil {
+		return nil, 0, err
+	}
+	records := make([]*db.EventRecord, 0, len(all))
+	for _, r := range all {
+		if r.Event == nil {
+			snapshot, err := d.p.Snapshots.LatestSnapshot(r.Key)
+			if err != nil {
+				return nil, 0, fmt.Errorf("获取活动 %s 的快照出错 %v", r.Key, err)
+			}
+			if snapshot != nil {
+				if r.Event, err = snapshot.Event(); err != nil {
+					return nil, 0, fmt.Errorf("解析活动 %s 的快照出错 %v", r.Key, err)
+				}
+				r.UpdatedAt = snapshot.CreatedAt
+			}
+		}
+		if q.Match(r) {
+			records = append(records, r)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].UpdatedAt.After(records[j].UpdatedAt)
+	})
+	total := len(records)
+	if q.Offset >= len(records) {
+		return []*db.EventRecord{}, total, nil
+	}
+	records = records[q.Offset:]
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, total, nil
+}
+
+// databases 守护进程使用的所有数据库，第一个为守护进程自身的数据库
+func (d *Daemon) databases() []db.DB {
+	dbs := []db.DB{d.d}
+	for _, sd := range d.dbs {
+		dbs = append(dbs, sd)
+	}
+	return dbs
+}
+
+// sourceDB 返回平台使用的数据库
+func (d *Daemon) sourceDB(source string) (db.DB, error) {
+	if len(d.sources) == 0 {
+		if err := d.register(); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range d.sources {
+		if r.s.Name() == source {
+			return r.d, nil
+		}
+	}
+	return nil, fmt.Errorf("没有配置平台 %s", source)
+}
+
+// snapshot 返回活动最近一次的快照，没有快照时返回 nil
+func (d *Daemon) snapshot(key string) (*utils.Event, error) {
+	snapshot, err := d.p.Snapshots.LatestSnapshot(key)
+	if err != nil {
+		return nil, fmt.Errorf("获取活动 %s 的快照出错 %v", key, err)
+	}
+	if snapshot == nil {
+		return nil, nil
+	}
+	e, err := snapshot.Event()
+	if err != nil {
+		return nil, fmt.Errorf("解析活动 %s 的快照出错 %v", key, err)
+	}
+	return e, nil
+}
@@ -0,0 +1,289 @@
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"show-live/config"
+	"show-live/utils"
+)
+
+// Filter 根据配置的规则决定活动是否需要通知
+type Filter struct {
+	// required 必须满足的规则，由旧配置中的 tags_selected 转换而来
+	required []*rule
+	include  []*rule
+	exclude  []*rule
+}
+
+// New 根据规则创建过滤器，多个配置会合并在一起，tags 为旧配置中的 tags_selected，
+// 与以前一样是必须满足的条件，活动还需要满足 include 规则。
+// 没有配置任何条件的规则（如字段名写错）会被忽略，否则一条空的 exclude 规则会排除所有活动
+func New(tags []string, confs ...config.Filter) (*Filter, error) {
+	f := &Filter{}
+	for _, conf := range confs {
+		for i, r := range conf.Include {
+			if empty(r) {
+				continue
+			}
+			compiled, err := compile(r)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 条 include 规则错误 %v", i+1, err)
+			}
+			f.include = append(f.include, compiled)
+		}
+		for i, r := range conf.Exclude {
+			if empty(r) {
+				continue
+			}
+			compiled, err := compile(r)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 条 exclude 规则错误 %v", i+1, err)
+			}
+			f.exclude = append(f.exclude, compiled)
+		}
+	}
+	if len(tags) != 0 {
+		f.required = append(f.required, &rule{tags: tags})
+	}
+	return f, nil
+}
+
+// Match 活动是否需要通知，没有 include 规则时默认需要通知
+func (f *Filter) Match(e *utils.Event) bool {
+	if f == nil {
+		return true
+	}
+	for _, r := range f.exclude {
+		if r.match(e) {
+			return false
+		}
+	}
+	for _, r := range f.required {
+		if !r.match(e) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, r := range f.include {
+		if r.match(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func empty(r config.Rule) bool {
+	return reflect.DeepEqual(r, config.Rule{})
+}
+
+type rule struct {
+	all, any           []*rule
+	not                *rule
+	sources            []string
+	tags               []string
+	title              *regexp.Regexp
+	artists            []string
+	venues             []string
+	cities             []string
+	priceMin, priceMax *float64
+	weekdays           []time.Weekday
+	dateFrom, dateTo   time.Time
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"周日": time.Sunday, "星期日": time.Sunday, "星期天": time.Sunday, "sunday": time.Sunday, "sun": time.Sunday,
+	"周一": time.Monday, "星期一": time.Monday, "monday": time.Monday, "mon": time.Monday,
+	"周二": time.Tuesday, "星期二": time.Tuesday, "tuesday": time.Tuesday, "tue": time.Tuesday,
+	"周三": time.Wednesday, "星期三": time.Wednesday, "wednesday": time.Wednesday, "wed": time.Wednesday,
+	"周四": time.Thursday, "星期四": time.Thursday, "thursday": time.Thursday, "thu": time.Thursday,
+	"周五": time.Friday, "星期五": time.Friday, "friday": time.Friday, "fri": time.Friday,
+	"周六": time.Saturday, "星期六": time.Saturday, "saturday": time.Saturday, "sat": time.Saturday,
+}
+
+func compile(conf config.Rule) (*rule, error) {
+	r := &rule{
+		sources:  conf.Sources,
+		tags:     conf.Tags,
+		artists:  conf.Artists,
+		venues:   conf.Venues,
+		cities:   conf.Cities,
+		priceMin: conf.PriceMin,
+		priceMax: conf.PriceMax,
+	}
+	for _, c := range conf.All {
+		compiled, err := compile(c)
+		if err != nil {
+			return nil, err
+		}
+		r.all = append(r.all, compiled)
+	}
+	for _, c := range conf.Any {
+		compiled, err := compile(c)
+		if err != nil {
+			return nil, err
+		}
+		r.any = append(r.any, compiled)
+	}
+	if conf.Not != nil {
+		compiled, err := compile(*conf.Not)
+		if err != nil {
+			return nil, err
+		}
+		r.not = compiled
+	}
+	if conf.Title != "" {
+		re, err := regexp.Compile(conf.Title)
+		if err != nil {
+			return nil, fmt.Errorf("标题正则表达式 %s 错误 %v", conf.Title, err)
+		}
+		r.title = re
+	}
+	for _, w := range conf.Weekdays {
+		weekday, ok := weekdayNames[strings.ToLower(strings.TrimSpace(w))]
+		if !ok {
+			return nil, fmt.Errorf("无法识别星期 %s", w)
+		}
+		r.weekdays = append(r.weekdays, weekday)
+	}
+	var err error
+	if conf.DateFrom != "" {
+		if r.dateFrom, err = time.ParseInLocation("2006-01-02", conf.DateFrom, utils.Location); err != nil {
+			return nil, fmt.Errorf("date_from %s 格式错误 %v", conf.DateFrom, err)
+		}
+	}
+	if conf.DateTo != "" {
+		if r.dateTo, err = time.ParseInLocation("2006-01-02", conf.DateTo, utils.Location); err != nil {
+			return nil, fmt.Errorf("date_to %s 格式错误 %v", conf.DateTo, err)
+		}
+	}
+	return r, nil
+}
+
+// match 规则中配置的所有条件都满足时才算满足，没有配置任何条件的规则总是满足
+func (r *rule) match(e *utils.Event) bool {
+	for _, c := range r.all {
+		if !c.match(e) {
+			return false
+		}
+	}
+	if len(r.any) != 0 {
+		found := false
+		for _, c := range r.any {
+			if c.match(e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.not != nil && r.not.match(e) {
+		return false
+	}
+	if len(r.sources) != 0 && !equalsAny(e.Source, r.sources) {
+		return false
+	}
+	if len(r.tags) != 0 && !tagsContainAny(e.Tags, r.tags) {
+		return false
+	}
+	if r.title != nil && !r.title.MatchString(e.Name) {
+		return false
+	}
+	if len(r.artists) != 0 && !containsAny(e.Artist, r.artists) {
+		return false
+	}
+	if len(r.venues) != 0 && !containsAny(e.Site, r.venues) {
+		return false
+	}
+	if len(r.cities) != 0 && !containsAny(e.City, r.cities) {
+		return false
+	}
+	if r.priceMin != nil || r.priceMax != nil {
+		price, ok := minPrice(e.Price)
+		if !ok || (r.priceMin != nil && price < *r.priceMin) || (r.priceMax != nil && price > *r.priceMax) {
+			return false
+		}
+	}
+	if len(r.weekdays) != 0 || !r.dateFrom.IsZero() || !r.dateTo.IsZero() {
+		date, ok := e.Date()
+		if !ok {
+			return false
+		}
+		if len(r.weekdays) != 0 && !weekdayIn(date.Weekday(), r.weekdays) {
+			return false
+		}
+		if !r.dateFrom.IsZero() && date.Before(r.dateFrom) {
+			return false
+		}
+		if !r.dateTo.IsZero() && date.After(r.dateTo) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalsAny(s string, values []string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(s string, values []string) bool {
+	if s == "" {
+		return false
+	}
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(s), strings.ToLower(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+func tagsContainAny(tags []string, values []string) bool {
+	for _, t := range tags {
+		if containsAny(t, values) {
+			return true
+		}
+	}
+	return false
+}
+
+func weekdayIn(w time.Weekday, weekdays []time.Weekday) bool {
+	for _, v := range weekdays {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
+
+var priceRegexp = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// minPrice 票价中出现的最小数字，如 ¥120-280 为 120
+func minPrice(s string) (float64, bool) {
+	found := false
+	var min float64
+	for _, m := range priceRegexp.FindAllString(s, -1) {
+		v, err := strconv.ParseFloat(m, 64)
+		if err != nil {
+			continue
+		}
+		if !found || v < min {
+			min = v
+			found = true
+		}
+	}
+	return min, found
+}
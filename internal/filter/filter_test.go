@@ -0,0 +1,154 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"show-live/config"
+	"show-live/utils"
+)
+
+func price(v float64) *float64 {
+	return &v
+}
+
+func TestMatch(t *testing.T) {
+	jazz := &utils.Event{
+		Source: "showstart",
+		Name:   "周末爵士夜",
+		Artist: "JZ Band、Trio",
+		Site:   "JZ Club",
+		City:   "上海",
+		Price:  "¥120-280",
+		Tags:   []string{"爵士"},
+		Start:  time.Date(2024, 3, 2, 20, 0, 0, 0, utils.Location), // 周六
+	}
+	rock := &utils.Event{
+		Source: "simullink",
+		Name:   "草东没有派对 巡演",
+		Artist: "草东没有派对",
+		Site:   "MAO Livehouse",
+		City:   "杭州",
+		Tags:   []string{"摇滚"},
+		Time:   "2024.03.05 20:00",
+	}
+	tests := []struct {
+		name   string
+		tags   []string
+		conf   config.Filter
+		events map[*utils.Event]bool
+	}{
+		{
+			name:   "没有规则时都需要通知",
+			events: map[*utils.Event]bool{jazz: true, rock: true},
+		},
+		{
+			name:   "include 满足任意一条即可",
+			conf:   config.Filter{Include: []config.Rule{{Artists: []string{"草东"}}, {Cities: []string{"北京"}}}},
+			events: map[*utils.Event]bool{jazz: false, rock: true},
+		},
+		{
+			name:   "exclude 优先于 include",
+			conf:   config.Filter{Include: []config.Rule{{Tags: []string{"爵士", "摇滚"}}}, Exclude: []config.Rule{{Title: "爵士夜$"}}},
+			events: map[*utils.Event]bool{jazz: false, rock: true},
+		},
+		{
+			name:   "同一条规则中的条件需要同时满足",
+			conf:   config.Filter{Exclude: []config.Rule{{Sources: []string{"showstart"}, Cities: []string{"杭州"}}}},
+			events: map[*utils.Event]bool{jazz: true, rock: true},
+		},
+		{
+			name:   "tags_selected 是必须满足的条件",
+			tags:   []string{"摇滚"},
+			conf:   config.Filter{Include: []config.Rule{{Venues: []string{"JZ Club"}}, {Artists: []string{"草东"}}}},
+			events: map[*utils.Event]bool{jazz: false, rock: true},
+		},
+		{
+			name:   "tags_selected 不能代替 include",
+			tags:   []string{"爵士", "摇滚"},
+			conf:   config.Filter{Include: []config.Rule{{Cities: []string{"上海"}}}},
+			events: map[*utils.Event]bool{jazz: true, rock: false},
+		},
+		{
+			name: "all 中的规则需要全部满足",
+			conf: config.Filter{Include: []config.Rule{{All: []config.Rule{
+				{Tags: []string{"爵士"}},
+				{Weekdays: []string{"周五", "周六"}},
+			}}}},
+			events: map[*utils.Event]bool{jazz: true, rock: false},
+		},
+		{
+			name: "any 中的规则满足任意一条即可",
+			conf: config.Filter{Exclude: []config.Rule{{Any: []config.Rule{
+				{PriceMin: price(100)},
+				{DateFrom: "2024-03-04", DateTo: "2024-03-06"},
+			}}}},
+			events: map[*utils.Event]bool{jazz: false, rock: false},
+		},
+		{
+			name:   "not 中的规则不能满足",
+			conf:   config.Filter{Include: []config.Rule{{Not: &config.Rule{Cities: []string{"杭州"}}}}},
+			events: map[*utils.Event]bool{jazz: true, rock: false},
+		},
+		{
+			name:   "不满足 exclude 的条件时不排除",
+			conf:   config.Filter{Exclude: []config.Rule{{Artists: []string{"万能青年旅店"}, Venues: []string{"育音堂"}}}},
+			events: map[*utils.Event]bool{jazz: true, rock: true},
+		},
+		{
+			name:   "活动缺少规则需要的字段时不排除",
+			conf:   config.Filter{Exclude: []config.Rule{{PriceMax: price(150)}}},
+			events: map[*utils.Event]bool{jazz: false, rock: true},
+		},
+		{
+			name:   "没有任何条件的规则被忽略",
+			conf:   config.Filter{Include: []config.Rule{{}}, Exclude: []config.Rule{{}}},
+			events: map[*utils.Event]bool{jazz: true, rock: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.tags, tt.conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for e, want := range tt.events {
+				if got := f.Match(e); got != want {
+					t.Errorf("%s：Match 返回 %v，应为 %v", e.Name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewError(t *testing.T) {
+	for _, r := range []config.Rule{
+		{Title: "("},
+		{Weekdays: []string{"周八"}},
+		{DateFrom: "2024/03/01"},
+		{All: []config.Rule{{DateTo: "x"}}},
+	} {
+		if _, err := New(nil, config.Filter{Include: []config.Rule{r}}); err == nil {
+			t.Errorf("规则 %+v 应返回错误", r)
+		}
+	}
+}
+
+func TestMinPrice(t *testing.T) {
+	tests := []struct {
+		s    string
+		want float64
+		ok   bool
+	}{
+		{"¥120-280", 120, true},
+		{"预售 88.5 / 现场 120", 88.5, true},
+		{"免费", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := minPrice(tt.s)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("minPrice(%q) = %v, %v，应为 %v, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
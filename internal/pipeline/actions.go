@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"show-live/internal/action"
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+// actionLinks 活动的操作链接：不感兴趣、要去、屏蔽艺人（每个艺人一个链接）、屏蔽场地
+func (p *Pipeline) actionLinks(e *utils.Event) string {
+	links := []string{
+		p.actionLink(action.NotInterested, e.Key(), ""),
+		p.actionLink(action.Going, e.Key(), ""),
+	}
+	for _, artist := range e.Artists() {
+		links = append(links, p.actionLink(action.MuteArtist, artist, artist))
+	}
+	if site := strings.TrimSpace(e.Site); site != "" {
+		links = append(links, p.actionLink(action.MuteVenue, site, site))
+	}
+	return fmt.Sprintf("<p><small>%s</small></p>", strings.Join(links, " | "))
+}
+
+func (p *Pipeline) actionLink(a, value, label string) string {
+	name := action.Names[a]
+	if label != "" {
+		name += " " + label
+	}
+	return fmt.Sprintf("<a href=\"%s\">%s</a>", p.Actions.URL(a, value), name)
+}
+
+// mutes 屏蔽的艺人和场地
+type mutes struct {
+	artists []string
+	venues  []string
+}
+
+// muted 读取屏蔽的艺人和场地，出错时不屏蔽
+func (p *Pipeline) muted() *mutes {
+	m := &mutes{}
+	if p.Mutes == nil {
+		return m
+	}
+	list, err := p.Mutes.Muted()
+	if err != nil {
+		log.Logger.Errorf("获取屏蔽的艺人和场地出错 %v", err)
+		return m
+	}
+	for _, v := range list {
+		switch v.Kind {
+		case db.MuteArtist:
+			m.artists = append(m.artists, v.Value)
+		case db.MuteVenue:
+			m.venues = append(m.venues, v.Value)
+		}
+	}
+	return m
+}
+
+// match 活动的某个艺人是屏蔽的艺人，或场地是屏蔽的场地。以前的链接会屏蔽活动的整个艺人字段，同样按整体比较
+func (m *mutes) match(e *utils.Event) bool {
+	artists := append(e.Artists(), strings.TrimSpace(e.Artist))
+	for _, a := range m.artists {
+		for _, artist := range artists {
+			if strings.EqualFold(artist, strings.TrimSpace(a)) {
+				return true
+			}
+		}
+	}
+	site := strings.TrimSpace(e.Site)
+	for _, v := range m.venues {
+		if site == v {
+			return true
+		}
+	}
+	return false
+}
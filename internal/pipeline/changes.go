@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"show-live/internal/source"
+	"show-live/pkg/log"
+	"show-live/pkg/notifier"
+	"show-live/utils"
+)
+
+type change struct {
+	field    string
+	old, new string
+}
+
+// diff 比较活动的票价、时间、场地、艺人
+func diff(old, new *utils.Event) []change {
+	changes := make([]change, 0)
+	for _, f := range []struct {
+		field    string
+		old, new string
+	}{
+		{"票价", old.Price, new.Price},
+		{"演出时间", old.Time, new.Time},
+		{"场地", old.Site, new.Site},
+		{"艺人", old.Artist, new.Artist},
+	} {
+		if strings.TrimSpace(f.old) != strings.TrimSpace(f.new) {
+			changes = append(changes, change{field: f.field, old: f.old, new: f.new})
+		}
+	}
+	return changes
+}
+
+// saveSnapshots 保存新活动的快照，作为之后比较的基准
+func (p *Pipeline) saveSnapshots(events []*utils.Event) {
+	if p.Snapshots == nil {
+		return
+	}
+	for _, e := range events {
+		if err := p.Snapshots.SaveSnapshot(e); err != nil {
+			log.Logger.Errorf("保存活动 %s 的快照出错 %v", e.Name, err)
+		}
+	}
+}
+
+// trackChanges 将已推送活动的最新信息与上一次的快照比较，有变化时发送通知，通知成功后才保存新的快照，
+// 通知失败时下次运行会再次比较出同样的变化
+func (p *Pipeline) trackChanges(s source.Source) {
+	if p.Snapshots == nil {
+		return
+	}
+	t, ok := s.(source.Tracker)
+	if !ok {
+		return
+	}
+	changed := make([]*utils.Event, 0)
+	var html, text string
+	for _, e := range t.KnownEvents() {
+		last, err := p.Snapshots.LatestSnapshot(e.Key())
+		if err != nil {
+			log.Logger.Errorf("获取活动 %s 的快照出错 %v", e.Name, err)
+			continue
+		}
+		if last == nil {
+			p.saveSnapshots([]*utils.Event{e})
+			continue
+		}
+		old, err := last.Event()
+		if err != nil {
+			log.Logger.Errorf("解析活动 %s 的快照出错 %v", e.Name, err)
+			continue
+		}
+		if old.Start.IsZero() && !e.Start.IsZero() {
+			// 旧的快照中没有解析后的演出时间，只更新快照作为之后比较的基准
+			p.saveSnapshots([]*utils.Event{e})
+			continue
+		}
+		changes := diff(old, e)
+		if len(changes) == 0 {
+			continue
+		}
+		changed = append(changed, e)
+		name := e.Name
+		if e.WebURL != "" {
+			name = fmt.Sprintf("<a href=\"%s\">%s</a>", e.WebURL, e.Name)
+		}
+		html += fmt.Sprintf("<p>🔄<strong>%s</strong>", name)
+		text += fmt.Sprintf("🔄%s", e.Name)
+		for _, c := range changes {
+			html += fmt.Sprintf("，<strong>%s</strong>：%s → <font color=Tomato>%s</font>", c.field, c.old, c.new)
+			text += fmt.Sprintf("，%s：%s → %s", c.field, c.old, c.new)
+		}
+		html += "</p>"
+		text += "\n"
+	}
+	if len(changed) == 0 {
+		return
+	}
+	title := fmt.Sprintf("%s有%d个已推送的演出信息变化了", s.DisplayName(), len(changed))
+	log.Logger.Infof("准备通知：%s，变化内容为：%s", title, text)
+	if err := p.notify(&notifier.Message{
+		Kind:   notifier.KindChange,
+		Title:  title,
+		HTML:   html,
+		Text:   strings.TrimSpace(text),
+		Events: changed,
+	}); err != nil {
+		log.Logger.Errorf("通知演出信息变化出错 %v", err)
+		return
+	}
+	p.saveSnapshots(changed)
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+const (
+	reminderDayBefore = "演出前一天"
+	reminderShowDay   = "演出当天"
+	// defaultDayBeforeRemindAt 演出前一天默认的提醒时间
+	defaultDayBeforeRemindAt = 20 * time.Hour
+	// defaultShowDayRemindAt 演出当天默认的提醒时间
+	defaultShowDayRemindAt = 9 * time.Hour
+)
+
+// MarkGoing 将活动标记为要去，并添加演出前一天和当天的提醒，d 为活动所属平台使用的数据库
+func (p *Pipeline) MarkGoing(d db.DB, e *utils.Event) error {
+	if err := d.SetKey(e.Key(), e.Name, db.EventGoing); err != nil {
+		return err
+	}
+	p.scheduleGoingReminders([]*utils.Event{e})
+	return nil
+}
+
+// SetStatus 修改活动在数据库中的状态，标记为要去时会添加演出提醒，从要去改为其他状态时会取消还未发送的演出提醒
+func (p *Pipeline) SetStatus(d db.DB, e *utils.Event, status string) error {
+	if status == db.EventGoing {
+		return p.MarkGoing(d, e)
+	}
+	if err := d.SetKey(e.Key(), e.Name, status); err != nil {
+		return err
+	}
+	if p.Reminders != nil {
+		return p.Reminders.CancelReminders(e.Key(), reminderDayBefore, reminderShowDay)
+	}
+	return nil
+}
+
+// scheduleGoingReminders 为要去的活动添加演出前一天和当天的提醒，演出时间变化时会更新提醒时间
+func (p *Pipeline) scheduleGoingReminders(events []*utils.Event) {
+	if p.Reminders == nil {
+		return
+	}
+	now := time.Now()
+	for _, e := range events {
+		date, ok := e.Date()
+		if !ok {
+			log.Logger.Errorf("无法从活动 %s 的演出时间 %s 中解析出日期，不添加演出提醒", e.Name, e.Time)
+			continue
+		}
+		note := fmt.Sprintf("演出时间：%s，场地：%s", e.Time, e.Site)
+		for _, r := range []struct {
+			kind string
+			at   time.Time
+		}{
+			{reminderDayBefore, date.AddDate(0, 0, -1).Add(p.DayBeforeRemindAt)},
+			{reminderShowDay, date.Add(p.ShowDayRemindAt)},
+		} {
+			if r.at.Before(now) {
+				continue
+			}
+			if err := p.Reminders.ScheduleReminder(r.kind, r.at, note, e); err != nil {
+				log.Logger.Errorf("添加活动 %s 的%s提醒出错 %v", e.Name, r.kind, err)
+			}
+		}
+	}
+}
+
+// going 返回数据库中标记为要去的活动
+func going(d db.DB, events []*utils.Event) []*utils.Event {
+	result := make([]*utils.Event, 0)
+	for _, e := range events {
+		value, err := d.GetValue(e.Key())
+		if err != nil {
+			log.Logger.Errorf("获取活动 %s 的状态出错 %v", e.Name, err)
+			continue
+		}
+		if value == db.EventGoing {
+			result = append(result, e)
+		}
+	}
+	return result
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"show-live/internal/source"
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/pkg/notifier"
+	"show-live/utils"
+)
+
+const (
+	// dropHistory 和 dropMinRuns 用最近多少次正常的运行计算活动数的平均值，少于 dropMinRuns 次时不检查
+	dropHistory = 20
+	dropMinRuns = 5
+	// dropRatio 活动列表中的活动数低于平均值的该比例时认为抓取异常
+	dropRatio = 0.5
+	// maxIncompleteShown 通知中最多列出多少个缺少字段的活动
+	maxIncompleteShown = 5
+)
+
+// checkHealth 检查抓取结果是否正常，平台的页面结构或接口变化时通常表现为活动列表为空、活动缺少名称等字段、
+// 活动数突然下降。发现异常时记录到本次运行的结果中，并在平台从正常变为异常时以高优先级单独通知
+func (p *Pipeline) checkHealth(s source.Source, events []*utils.Event, r *db.Run) {
+	problems := incomplete(events)
+	if c, ok := s.(source.Checker); ok {
+		r.Seen = c.Seen()
+		problems = append(problems, c.Problems()...)
+		if msg := p.dropped(s.Name(), r.Seen); msg != "" {
+			problems = append(problems, msg)
+		}
+	}
+	r.Problems = strings.Join(problems, "\n")
+	last := p.lastHealth(s.Name())
+	defer p.setHealth(s.Name(), r)
+	if len(problems) == 0 {
+		if last.broken {
+			log.Logger.Infof("%s的抓取结果已恢复正常", s.DisplayName())
+		}
+		return
+	}
+	log.Logger.Errorf("%s的抓取结果异常：\n%s", s.DisplayName(), r.Problems)
+	if last.broken && last.alerted {
+		// 异常持续时只通知一次，避免每次运行都打扰
+		r.Alerted = true
+		return
+	}
+	paragraphs := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		paragraphs = append(paragraphs, "<p>"+html.EscapeString(problem)+"</p>")
+	}
+	if err := p.notify(&notifier.Message{
+		Kind:     notifier.KindAlert,
+		Title:    fmt.Sprintf("⚠️%s抓取异常，页面结构可能变了", s.DisplayName()),
+		HTML:     strings.Join(paragraphs, ""),
+		Text:     r.Problems,
+		Priority: true,
+	}); err != nil {
+		// 没有送达时下次运行会再次通知
+		log.Logger.Errorf("通知%s抓取异常出错 %v", s.DisplayName(), err)
+		return
+	}
+	r.Alerted = true
+}
+
+// healthState 一次运行的抓取结果是否异常，以及异常的通知是否已经送达
+type healthState struct {
+	broken, alerted bool
+}
+
+// lastHealth 上次运行时平台的抓取结果。保存了运行结果时按上一次检查过抓取结果的运行判断，重启后也不会重复通知，
+// 获取活动出错的运行没有检查抓取结果，跳过；没有保存运行结果时使用内存中的记录
+func (p *Pipeline) lastHealth(source string) healthState {
+	p.healthLock.Lock()
+	last := p.health[source]
+	p.healthLock.Unlock()
+	if p.Runs == nil {
+		return last
+	}
+	runs, err := p.Runs.RecentRuns(source, dropHistory)
+	if err != nil {
+		log.Logger.Errorf("查询%s最近的运行结果出错 %v", source, err)
+		return last
+	}
+	for _, r := range runs {
+		if r.Error != "" && r.Problems == "" && r.Found == 0 && r.Seen == 0 {
+			continue
+		}
+		return healthState{broken: r.Problems != "", alerted: r.Alerted}
+	}
+	return healthState{}
+}
+
+func (p *Pipeline) setHealth(source string, r *db.Run) {
+	p.healthLock.Lock()
+	defer p.healthLock.Unlock()
+	p.health[source] = healthState{broken: r.Problems != "", alerted: r.Alerted}
+}
+
+// incomplete 检查活动是否缺少名称、场地、时间
+func incomplete(events []*utils.Event) []string {
+	names := make([]string, 0)
+	count := 0
+	for _, e := range events {
+		missing := make([]string, 0, 3)
+		if strings.TrimSpace(e.Name) == "" {
+			missing = append(missing, "名称")
+		}
+		if strings.TrimSpace(e.Site) == "" {
+			missing = append(missing, "场地")
+		}
+		if strings.TrimSpace(e.Time) == "" {
+			missing = append(missing, "时间")
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		count++
+		if len(names) < maxIncompleteShown {
+			names = append(names, fmt.Sprintf("%s 缺少%s", e.Key(), strings.Join(missing, "、")))
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%d 个活动缺少名称、场地或时间：%s", count, strings.Join(names, "；"))}
+}
+
+// dropped 与最近正常运行时活动列表中活动数的平均值比较，下降过多时返回异常信息
+func (p *Pipeline) dropped(source string, seen int) string {
+	if p.Runs == nil {
+		return ""
+	}
+	runs, err := p.Runs.RecentRuns(source, dropHistory)
+	if err != nil {
+		log.Logger.Errorf("查询%s最近的运行结果出错 %v", source, err)
+		return ""
+	}
+	total, count := 0, 0
+	for _, r := range runs {
+		if r.Error != "" || r.Problems != "" || r.Seen == 0 {
+			continue
+		}
+		total += r.Seen
+		count++
+	}
+	if count < dropMinRuns {
+		return ""
+	}
+	avg := float64(total) / float64(count)
+	if float64(seen) >= avg*dropRatio {
+		return ""
+	}
+	return fmt.Sprintf("活动列表中只有 %d 个活动，最近 %d 次正常运行平均有 %.0f 个", seen, count, avg)
+}
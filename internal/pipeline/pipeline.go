@@ -0,0 +1,505 @@
+package pipeline
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"show-live/internal/action"
+	"show-live/internal/filter"
+	"show-live/internal/source"
+	"show-live/internal/watchlist"
+	"show-live/pkg/cover"
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/pkg/notifier"
+	"show-live/utils"
+)
+
+const (
+	notifyTryTimes = 10
+	// outboxMaxAttempts 发件箱中的通知最多尝试的次数，超过后不再重试
+	outboxMaxAttempts = 12
+	outboxBaseBackoff = time.Minute
+	outboxMaxBackoff  = 6 * time.Hour
+)
+
+// Pipeline 对所有来源平台使用相同的流程：获取活动、下载封面、发送通知、标记为已推送
+type Pipeline struct {
+	notifiers []notifier.Notifier
+	// CoverDir 封面的保存目录，为空时不下载封面
+	CoverDir string
+	// Outbox 持久化的发件箱，为空时直接发送通知，失败的活动在下次运行时会被重新获取
+	Outbox db.Outbox
+	// Snapshots 活动快照，为空时不检查已推送活动的变化
+	Snapshots db.Snapshots
+	// Reminders 提醒，为空时不添加开售提醒和演出提醒
+	Reminders db.Reminders
+	// SaleRemindBefore 开售前多久提醒，为0时不添加开售提醒
+	SaleRemindBefore time.Duration
+	// DayBeforeRemindAt 和 ShowDayRemindAt 为要去的活动在演出前一天和当天提醒的时间，为距离零点的时间
+	DayBeforeRemindAt time.Duration
+	ShowDayRemindAt   time.Duration
+	// Mutes 屏蔽的艺人和场地，为空时不检查
+	Mutes db.Mutes
+	// Actions 通知邮件中操作链接的签名，为空时不添加操作链接
+	Actions *action.Signer
+	// Runs 每次运行的结果，为空时不保存
+	Runs db.Runs
+	// Watchlist 关注的艺人，这些艺人的活动不经过过滤规则，并且会单独以高优先级立即通知
+	Watchlist *watchlist.Watchlist
+	// filters 各来源平台的过滤规则
+	filters map[string]*filter.Filter
+	// health 上次运行时各平台的抓取结果，没有保存运行结果时使用
+	health     map[string]healthState
+	healthLock sync.Mutex
+}
+
+func New(notifiers []notifier.Notifier) *Pipeline {
+	return &Pipeline{
+		notifiers:         notifiers,
+		DayBeforeRemindAt: defaultDayBeforeRemindAt,
+		ShowDayRemindAt:   defaultShowDayRemindAt,
+		filters:           make(map[string]*filter.Filter),
+		health:            make(map[string]healthState),
+	}
+}
+
+// SetFilter 设置来源平台的过滤规则，没有设置的平台不过滤
+func (p *Pipeline) SetFilter(source string, f *filter.Filter) {
+	p.filters[source] = f
+}
+
+// Run 从来源平台获取需要通知的活动，并以统一的格式发送到所有通知渠道，d 为来源平台使用的数据库
+func (p *Pipeline) Run(s source.Source, d db.DB) error {
+	r := &db.Run{Source: s.Name(), StartedAt: time.Now()}
+	err := p.run(s, d, r)
+	p.saveRun(r, err)
+	return err
+}
+
+func (p *Pipeline) run(s source.Source, d db.DB, r *db.Run) error {
+	events, err := s.GetEventsToNotify()
+	if err != nil && events == nil {
+		log.Logger.Errorf("获取%s需要通知的活动出错 %v", s.DisplayName(), err)
+		p.notify(&notifier.Message{
+			Kind:  notifier.KindAlert,
+			Title: fmt.Sprintf("%s获取最新演出出错了", s.DisplayName()),
+			Text:  err.Error(),
+		})
+		return err
+	}
+	if err != nil {
+		// 只有部分请求出错，获取到的活动照常通知，错误记录在本次运行的结果中
+		log.Logger.Errorf("获取%s的部分活动出错 %v", s.DisplayName(), err)
+	}
+	if notifyErr := p.handle(s, d, r, events); notifyErr != nil {
+		return notifyErr
+	}
+	return err
+}
+
+// handle 检查、过滤获取到的活动并发送通知
+func (p *Pipeline) handle(s source.Source, d db.DB, r *db.Run, events []*utils.Event) error {
+	startTime := r.StartedAt
+	r.Found = len(events)
+	p.checkHealth(s, events, r)
+	watched, events := p.splitWatched(events)
+	events = p.filter(s, d, events)
+	r.Filtered = r.Found - len(watched) - len(events)
+	utils.SortByStart(watched)
+	utils.SortByStart(events)
+	p.saveSnapshots(watched)
+	p.saveSnapshots(events)
+	p.trackChanges(s)
+	p.scheduleSaleReminders(watched)
+	p.scheduleSaleReminders(events)
+	if t, ok := s.(source.Tracker); ok {
+		known := t.KnownEvents()
+		p.scheduleSaleReminders(known)
+		p.scheduleGoingReminders(going(d, known))
+	}
+	r.Notified = len(watched) + len(events)
+	r.EventKeys = keys(append(append([]*utils.Event{}, watched...), events...))
+	endTime := time.Now()
+	if len(watched) != 0 {
+		p.saveCovers(watched)
+		if !p.notifyWatched(s, d, startTime, endTime, watched) {
+			// 立即通知失败时和其他活动一起走正常的通知流程
+			events = append(watched, events...)
+		}
+	}
+	if len(events) == 0 {
+		log.Logger.Infof("%s没有活动需要通知.........", s.DisplayName())
+	}
+	p.saveCovers(events)
+	if p.Outbox != nil {
+		return p.runOutbox(s, d, r, startTime, endTime, events)
+	}
+	if len(events) == 0 && !notifyEmpty(s, r) {
+		return nil
+	}
+	cont := p.Content(startTime, endTime, events)
+	log.Logger.Infof("准备通知，通知内容为: %s", cont)
+	if err := p.notify(&notifier.Message{
+		Kind:   notifier.KindNew,
+		Title:  fmt.Sprintf("%s上新了%d个演出", s.DisplayName(), len(events)),
+		HTML:   cont,
+		Events: events,
+		Images: images(events),
+	}); err != nil {
+		log.Logger.Infof("通知活动时出错：%v", err)
+		return err
+	}
+	markPushed(d, events)
+	log.Logger.Infof("成功通知了 %d 个活动........", len(events))
+	return nil
+}
+
+// saveRun 保存运行的结果
+func (p *Pipeline) saveRun(r *db.Run, err error) {
+	if p.Runs == nil {
+		return
+	}
+	r.FinishedAt = time.Now()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if err := p.Runs.SaveRun(r); err != nil {
+		log.Logger.Errorf("保存%s的运行结果出错 %v", r.Source, err)
+	}
+}
+
+func keys(events []*utils.Event) string {
+	keys := make([]string, 0, len(events))
+	for _, e := range events {
+		keys = append(keys, e.Key())
+	}
+	return strings.Join(keys, ",")
+}
+
+// splitWatched 分离出关注的艺人的活动
+func (p *Pipeline) splitWatched(events []*utils.Event) (watched []*utils.Event, others []*utils.Event) {
+	for _, e := range events {
+		if len(p.Watchlist.Match(e)) != 0 {
+			watched = append(watched, e)
+		} else {
+			others = append(others, e)
+		}
+	}
+	return watched, others
+}
+
+// notifyWatched 以高优先级立即通知关注的艺人的活动，成功送达任意一个渠道后标记为已推送
+func (p *Pipeline) notifyWatched(s source.Source, d db.DB, start, end time.Time, events []*utils.Event) bool {
+	artists := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, e := range events {
+		for _, a := range p.Watchlist.Match(e) {
+			if !seen[a] {
+				seen[a] = true
+				artists = append(artists, a)
+			}
+		}
+	}
+	title := fmt.Sprintf("⭐关注的艺人%s在%s上新了%d个演出", strings.Join(artists, "、"), s.DisplayName(), len(events))
+	log.Logger.Infof("准备立即通知：%s", title)
+	if err := p.notify(&notifier.Message{
+		Kind:     notifier.KindNew,
+		Title:    title,
+		HTML:     p.Content(start, end, events),
+		Events:   events,
+		Images:   images(events),
+		Priority: true,
+	}); err != nil {
+		log.Logger.Errorf("立即通知关注的艺人的活动出错 %v", err)
+		return false
+	}
+	markPushed(d, events)
+	return true
+}
+
+// filter 过滤掉不需要通知的活动，并在数据库中标记为不感兴趣，之后不再请求
+func (p *Pipeline) filter(s source.Source, d db.DB, events []*utils.Event) []*utils.Event {
+	f := p.filters[s.Name()]
+	muted := p.muted()
+	kept := make([]*utils.Event, 0, len(events))
+	for _, e := range events {
+		if f.Match(e) && !muted.match(e) {
+			kept = append(kept, e)
+			continue
+		}
+		if err := d.SetKey(e.Key(), e.Name, db.EventNotInterested); err != nil {
+			log.Logger.Errorf("标记活动 %s 为不感兴趣出错 %v", e.Name, err)
+		}
+	}
+	if len(kept) != len(events) {
+		log.Logger.Infof("%s过滤掉了 %d 个不感兴趣的活动", s.DisplayName(), len(events)-len(kept))
+	}
+	return kept
+}
+
+// runOutbox 将活动加入每个通知渠道的发件箱，再发送每个渠道中到了重试时间的通知，
+// 任意一个渠道送达后活动即标记为已推送
+func (p *Pipeline) runOutbox(s source.Source, d db.DB, r *db.Run, start, end time.Time, events []*utils.Event) error {
+	for _, e := range events {
+		enqueued := false
+		for i, n := range p.notifiers {
+			if err := p.Outbox.EnqueueOutbox(notifierID(i, n), e); err != nil {
+				log.Logger.Errorf("将活动 %s 加入 %s 的发件箱出错 %v", e.Name, n.Name(), err)
+				continue
+			}
+			enqueued = true
+		}
+		if !enqueued {
+			// 没有加入任何发件箱时保持原来的状态，下次运行时重新获取
+			continue
+		}
+		if err := d.SetKey(e.Key(), e.Name, db.EventPending); err != nil {
+			log.Logger.Errorf("标记活动 %s 为待推送出错 %v", e.Name, err)
+		}
+	}
+	var errToReturn error
+	sent := false
+	for i, n := range p.notifiers {
+		items, err := p.Outbox.DueOutbox(s.Name(), notifierID(i, n), time.Now())
+		if err != nil {
+			log.Logger.Errorf("获取 %s 发件箱中的通知出错 %v", n.Name(), err)
+			errToReturn = err
+			continue
+		}
+		if len(items) == 0 {
+			continue
+		}
+		sent = true
+		if err := p.deliver(s, d, n, items, start, end); err != nil {
+			errToReturn = err
+		}
+	}
+	if !sent && notifyEmpty(s, r) {
+		// 没有需要通知的活动时仍然发送一次，用来确认服务在正常运行
+		return p.notify(&notifier.Message{
+			Kind:  notifier.KindNew,
+			Title: fmt.Sprintf("%s上新了0个演出", s.DisplayName()),
+			HTML:  p.Content(start, end, nil),
+		})
+	}
+	return errToReturn
+}
+
+// deliver 将发件箱中的通知合并为一条消息发送，根据结果更新发件箱
+func (p *Pipeline) deliver(s source.Source, d db.DB, n notifier.Notifier, items []*db.OutboxItem, start, end time.Time) error {
+	events := make([]*utils.Event, 0, len(items))
+	for _, item := range items {
+		e, err := item.Event()
+		if err != nil {
+			log.Logger.Errorf("解析发件箱中的活动 %s 出错 %v", item.EventKey, err)
+			continue
+		}
+		events = append(events, e)
+	}
+	cont := p.Content(start, end, events)
+	log.Logger.Infof("准备通过 %s 通知，通知内容为: %s", n.Name(), cont)
+	err := n.Notify(&notifier.Message{
+		Kind:   notifier.KindNew,
+		Title:  fmt.Sprintf("%s上新了%d个演出", s.DisplayName(), len(events)),
+		HTML:   cont,
+		Events: events,
+		Images: images(events),
+	})
+	now := time.Now()
+	failed := make([]*db.OutboxItem, 0)
+	for _, item := range items {
+		item.Attempts++
+		if err == nil {
+			item.Status = db.OutboxDelivered
+			item.LastError = ""
+		} else {
+			item.LastError = err.Error()
+			item.NextAttemptAt = now.Add(backoff(item.Attempts))
+			if item.Attempts >= outboxMaxAttempts {
+				item.Status = db.OutboxFailed
+			}
+		}
+		if err := p.Outbox.SaveOutbox(item); err != nil {
+			log.Logger.Errorf("保存发件箱中的通知 %s 出错 %v", item.EventKey, err)
+			continue
+		}
+		if item.Status == db.OutboxFailed {
+			failed = append(failed, item)
+		}
+	}
+	p.markUndelivered(d, failed)
+	if err != nil {
+		log.Logger.Errorf("通过 %s 通知 %d 个活动失败 %v", n.Name(), len(events), err)
+		return err
+	}
+	markPushed(d, events)
+	log.Logger.Infof("通过 %s 成功通知了 %d 个活动........", n.Name(), len(events))
+	return nil
+}
+
+// markUndelivered 活动在所有通知渠道中都推送失败时不再是待推送，标记为推送失败，
+// 否则活动会一直处于待推送状态，不会再被通知
+func (p *Pipeline) markUndelivered(d db.DB, items []*db.OutboxItem) {
+	for _, item := range items {
+		all, err := p.Outbox.AllFailed(item.EventKey)
+		if err != nil {
+			log.Logger.Errorf("检查活动 %s 是否在所有通知渠道中都推送失败出错 %v", item.EventKey, err)
+			continue
+		}
+		if !all {
+			continue
+		}
+		log.Logger.Errorf("活动 %s 在所有通知渠道中尝试 %d 次后都推送失败 %s", item.EventKey, outboxMaxAttempts, item.LastError)
+		name := item.EventKey
+		if e, err := item.Event(); err == nil {
+			name = e.Name
+		}
+		if err := d.SetKey(item.EventKey, name, db.EventUndelivered); err != nil {
+			log.Logger.Errorf("标记活动 %s 为推送失败出错 %v", item.EventKey, err)
+		}
+	}
+}
+
+// notifyEmpty 平台没有新活动时是否也发送通知。本次抓取结果异常时不发送，
+// 否则"上新了0个演出"会让人以为一切正常，异常已经单独通知
+func notifyEmpty(s source.Source, r *db.Run) bool {
+	if r.Problems != "" {
+		return false
+	}
+	h, ok := s.(source.Heartbeat)
+	return ok && h.NotifyEmpty()
+}
+
+// notifierID 通知渠道在发件箱中的标识，同一类型可以配置多个渠道，因此带上配置中的序号
+func notifierID(i int, n notifier.Notifier) string {
+	return fmt.Sprintf("%s_%d", n.Name(), i)
+}
+
+// backoff 第 attempts 次失败后等待的时间，从 outboxBaseBackoff 开始翻倍，最长 outboxMaxBackoff
+func backoff(attempts int) time.Duration {
+	d := outboxBaseBackoff
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > outboxMaxBackoff {
+		d = outboxMaxBackoff
+	}
+	return d
+}
+
+func markPushed(d db.DB, events []*utils.Event) {
+	for _, e := range events {
+		if err := d.SetKey(e.Key(), e.Name, db.EventPushed); err != nil {
+			log.Logger.Errorf("标记活动 %s 为已推送出错 %v", e.Name, err)
+		}
+	}
+}
+
+// saveCovers 下载活动的封面
+func (p *Pipeline) saveCovers(events []*utils.Event) {
+	if p.CoverDir == "" {
+		return
+	}
+	for _, e := range events {
+		if e.Cover == "" {
+			continue
+		}
+		file, err := cover.Save(p.CoverDir, e.Cover)
+		if err != nil {
+			log.Logger.Errorf("保存活动 %s 的封面出错 %v", e.Name, err)
+			continue
+		}
+		e.CoverFile = file
+	}
+}
+
+// images 需要内嵌到邮件中的封面图片
+func images(events []*utils.Event) []string {
+	files := make([]string, 0, len(events))
+	seen := make(map[string]bool)
+	for _, e := range events {
+		if e.CoverFile != "" && !seen[e.CoverFile] {
+			seen[e.CoverFile] = true
+			files = append(files, e.CoverFile)
+		}
+	}
+	return files
+}
+
+// notify 发送到所有通知渠道，某个渠道失败不影响其他渠道，所有渠道都失败时返回最后一个错误
+func (p *Pipeline) notify(msg *notifier.Message) error {
+	var errToReturn error
+	delivered := false
+	for _, n := range p.notifiers {
+		if err := tryNotify(n, msg); err != nil {
+			log.Logger.Errorf("通过 %s 通知失败 %v", n.Name(), err)
+			errToReturn = err
+			continue
+		}
+		delivered = true
+	}
+	if delivered {
+		return nil
+	}
+	return errToReturn
+}
+
+func tryNotify(n notifier.Notifier, msg *notifier.Message) error {
+	if r, ok := n.(notifier.Retrier); ok && r.SelfRetry() {
+		return n.Notify(msg)
+	}
+	var errToReturn error
+	for i := 0; i < notifyTryTimes; i++ {
+		err := n.Notify(msg)
+		if err == nil {
+			break
+		}
+		if err != nil {
+			log.Logger.Errorf("通过 %s 通知失败 %v, 第 %d 次失败", n.Name(), err, i+1)
+		}
+		if i == notifyTryTimes-1 {
+			errToReturn = err
+		}
+		time.Sleep(time.Second)
+	}
+	return errToReturn
+}
+
+// Content 生成通知邮件的HTML内容
+func (p *Pipeline) Content(start, end time.Time, events []*utils.Event) string {
+	time := fmt.Sprintf("<p>开始运行时间：%s，结束时间：%s</p>", start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"))
+	if len(events) == 0 {
+		return time + "<p>没有活动需要通知</p>"
+	}
+	r := fmt.Sprintf("%s<p>购票前务必先看大麦与确认是否有空观看，即使显示独家也要确认大麦！</p>", time)
+	for _, e := range events {
+		r += p.eventContent(e)
+	}
+	return r
+}
+
+func (p *Pipeline) eventContent(e *utils.Event) string {
+	name := fmt.Sprintf("<font color=green></strong>%s<strong></font>", e.Name)
+	if e.WebURL != "" {
+		name = fmt.Sprintf("<a href=\"%s\">%s</a>", e.WebURL, name)
+	}
+	r := fmt.Sprintf("<p>🌈%s，<strong>演出时间</strong>：%s，"+
+		"<strong>艺人</strong>： %s，<strong>场地</strong><font color=Tomato>：%s</font>，<strong>票价</strong>：%s",
+		name, e.Time, e.Artist, e.Site, e.Price,
+	)
+	if e.WebViewURL != "" {
+		r += fmt.Sprintf("，<a href=\"%s\">App内查看详情</a>", e.WebViewURL)
+	}
+	r += "</p>"
+	if p.Actions != nil {
+		r += p.actionLinks(e)
+	}
+	if e.CoverFile != "" {
+		r += fmt.Sprintf("<p><img src=\"cid:%s\" width=\"240\"></p>", filepath.Base(e.CoverFile))
+	}
+	return r
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+
+	"show-live/config"
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/pkg/notifier"
+	"show-live/utils"
+)
+
+const (
+	reminderSale       = "开售"
+	reminderMemberSale = "会员开售"
+	// reminderExpireAfter 超过提醒时间太久的提醒不再发送，如服务停止了一段时间
+	reminderExpireAfter = 12 * time.Hour
+)
+
+// SetReminders 根据配置设置提醒，开售提醒只在配置了 sale_before 时添加
+func (p *Pipeline) SetReminders(r db.Reminders, conf config.Reminder) error {
+	if conf.SaleBefore != "" {
+		before, err := time.ParseDuration(conf.SaleBefore)
+		if err != nil {
+			return fmt.Errorf("解析开售提醒时间 %s 出错 %v", conf.SaleBefore, err)
+		}
+		p.SaleRemindBefore = before
+	}
+	if conf.DayBefore != "" {
+		at, err := utils.ParseClock(conf.DayBefore)
+		if err != nil {
+			return fmt.Errorf("解析演出前一天的提醒时间 %s 出错 %v", conf.DayBefore, err)
+		}
+		p.DayBeforeRemindAt = at
+	}
+	if conf.ShowDay != "" {
+		at, err := utils.ParseClock(conf.ShowDay)
+		if err != nil {
+			return fmt.Errorf("解析演出当天的提醒时间 %s 出错 %v", conf.ShowDay, err)
+		}
+		p.ShowDayRemindAt = at
+	}
+	p.Reminders = r
+	return nil
+}
+
+// scheduleSaleReminders 为还未开售的活动添加开售提醒，开售时间变化时会更新提醒时间
+func (p *Pipeline) scheduleSaleReminders(events []*utils.Event) {
+	if p.Reminders == nil || p.SaleRemindBefore <= 0 {
+		return
+	}
+	now := time.Now()
+	for _, e := range events {
+		for _, r := range []struct {
+			kind string
+			at   time.Time
+		}{
+			{reminderSale, e.SellTime},
+			{reminderMemberSale, e.SellMemberTime},
+		} {
+			if r.at.IsZero() || r.at.Before(now) {
+				continue
+			}
+			note := fmt.Sprintf("门票将于 %s %s", r.at.In(utils.Location).Format("01-02 15:04"), r.kind)
+			if err := p.Reminders.ScheduleReminder(r.kind, r.at.Add(-p.SaleRemindBefore), note, e); err != nil {
+				log.Logger.Errorf("添加活动 %s 的%s提醒出错 %v", e.Name, r.kind, err)
+			}
+		}
+	}
+}
+
+// SendReminders 发送所有到了提醒时间的提醒，常驻运行时需要定期调用
+func (p *Pipeline) SendReminders() error {
+	if p.Reminders == nil {
+		return nil
+	}
+	now := time.Now()
+	reminders, err := p.Reminders.DueReminders(now)
+	if err != nil {
+		return fmt.Errorf("获取需要发送的提醒出错 %v", err)
+	}
+	due := make([]*db.Reminder, 0, len(reminders))
+	events := make([]*utils.Event, 0, len(reminders))
+	var html, text string
+	for _, r := range reminders {
+		e, err := r.Event()
+		if err != nil || now.Sub(r.RemindAt) > reminderExpireAfter {
+			r.Status = db.ReminderExpired
+			if err := p.Reminders.SaveReminder(r); err != nil {
+				log.Logger.Errorf("保存提醒 %d 出错 %v", r.ID, err)
+			}
+			continue
+		}
+		due = append(due, r)
+		events = append(events, e)
+		name := e.Name
+		if e.WebURL != "" {
+			name = fmt.Sprintf("<a href=\"%s\">%s</a>", e.WebURL, e.Name)
+		}
+		html += fmt.Sprintf("<p>⏰<strong>%s提醒</strong>：<font color=green>%s</font>，%s</p>", r.Kind, name, r.Note)
+		text += fmt.Sprintf("⏰%s提醒：%s，%s\n", r.Kind, e.Name, r.Note)
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	title := fmt.Sprintf("⏰有%d个演出提醒", len(due))
+	if len(due) == 1 {
+		title = fmt.Sprintf("⏰%s：%s", events[0].Name, due[0].Note)
+	}
+	log.Logger.Infof("准备发送提醒：%s", text)
+	if err := p.notify(&notifier.Message{
+		Kind:     notifier.KindReminder,
+		Title:    title,
+		HTML:     html,
+		Text:     text,
+		Events:   events,
+		Priority: true,
+	}); err != nil {
+		return fmt.Errorf("发送提醒出错 %v", err)
+	}
+	for _, r := range due {
+		r.Status = db.ReminderSent
+		if err := p.Reminders.SaveReminder(r); err != nil {
+			log.Logger.Errorf("保存提醒 %d 出错 %v", r.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+
+	"show-live/internal/action"
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+)
+
+var actionTemplate = template.Must(template.New("action.html").ParseFS(templates, "templates/action.html"))
+
+type actionData struct {
+	Name   string
+	Value  string
+	Done   bool
+	Error  string
+	Action string
+	Sig    string
+}
+
+// action 处理通知邮件中的操作链接。打开链接时先显示确认页面，确认后才执行，避免邮件客户端预先访问链接时误操作
+func (s *Server) action(w http.ResponseWriter, r *http.Request) {
+	if s.signer == nil {
+		http.NotFound(w, r)
+		return
+	}
+	a, value, sig := r.FormValue("a"), r.FormValue("v"), r.FormValue("sig")
+	name, ok := action.Names[a]
+	if !ok || !s.signer.Verify(a, value, sig) {
+		http.Error(w, "无效的链接", http.StatusForbidden)
+		return
+	}
+	data := &actionData{Name: name, Value: value, Action: a, Sig: sig}
+	if r.Method == http.MethodPost {
+		var err error
+		switch a {
+		case action.NotInterested:
+			err = s.store.SetStatus(value, "", db.EventNotInterested)
+		case action.Going:
+			err = s.store.SetStatus(value, "", db.EventGoing)
+		case action.MuteArtist:
+			err = s.store.Mute(db.MuteArtist, value)
+		case action.MuteVenue:
+			err = s.store.Mute(db.MuteVenue, value)
+		}
+		if err != nil {
+			log.Logger.Errorf("执行操作 %s %s 出错 %v", name, value, err)
+			data.Error = err.Error()
+		} else {
+			log.Logger.Infof("已执行操作 %s %s", name, value)
+			data.Done = true
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := actionTemplate.Execute(w, data); err != nil {
+		log.Logger.Errorf("渲染页面出错 %v", err)
+	}
+}
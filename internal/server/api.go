@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+const (
+	defaultAPILimit = 50
+	maxAPILimit     = 500
+)
+
+// apiEvent 接口返回的活动，event 与通知渠道（如 webhook）使用相同的结构
+type apiEvent struct {
+	Key       string       `json:"key"`
+	Status    string       `json:"status"`
+	UpdatedAt *time.Time   `json:"updated_at,omitempty"`
+	Event     *utils.Event `json:"event,omitempty"`
+}
+
+type apiEvents struct {
+	Total  int         `json:"total"`
+	Events []*apiEvent `json:"events"`
+}
+
+type apiRun struct {
+	ID         uint      `json:"id"`
+	Source     string    `json:"source"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Found      int       `json:"found"`
+	Filtered   int       `json:"filtered"`
+	Notified   int       `json:"notified"`
+	Seen       int       `json:"seen"`
+	Events     []string  `json:"events"`
+	Problems   []string  `json:"problems"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiEvents 按条件查询活动，支持的参数：
+// source、status（多个以逗号分隔，all 为所有状态，默认为已推送和要去）、city、tag、venue、q、
+// from、to（演出日期，格式为 2006-01-02，包含这两天）、limit、offset
+func (s *Server) apiEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "只支持 GET 请求"})
+		return
+	}
+	query := r.URL.Query()
+	q := db.EventQuery{
+		Source: query.Get("source"),
+		City:   query.Get("city"),
+		Tag:    query.Get("tag"),
+		Venue:  query.Get("venue"),
+		Search: query.Get("q"),
+		Limit:  defaultAPILimit,
+	}
+	switch status := query.Get("status"); status {
+	case "":
+		q.Statuses = []string{db.EventPushed, db.EventGoing}
+	case "all":
+	default:
+		q.Statuses = strings.Split(status, ",")
+	}
+	var err error
+	if q.From, err = parseDate(query.Get("from")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "from 的格式应为 2006-01-02"})
+		return
+	}
+	if q.To, err = parseDate(query.Get("to")); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "to 的格式应为 2006-01-02"})
+		return
+	}
+	if !q.To.IsZero() {
+		q.To = q.To.AddDate(0, 0, 1)
+	}
+	if v, err := strconv.Atoi(query.Get("limit")); err == nil && v > 0 && v <= maxAPILimit {
+		q.Limit = v
+	}
+	if v, err := strconv.Atoi(query.Get("offset")); err == nil && v > 0 {
+		q.Offset = v
+	}
+	records, total, err := s.store.QueryEvents(q)
+	if err != nil {
+		log.Logger.Errorf("查询活动出错 %v", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	resp := apiEvents{Total: total, Events: make([]*apiEvent, 0, len(records))}
+	for _, record := range records {
+		resp.Events = append(resp.Events, toAPIEvent(record))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// apiEvent 查询单个活动，路径为 /api/events/<活动在数据库中的键>，如 /api/events/showstart_eventid_123
+func (s *Server) apiEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "只支持 GET 请求"})
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/api/events/")
+	records, _, err := s.store.QueryEvents(db.EventQuery{Key: key, Limit: 1})
+	if err != nil {
+		log.Logger.Errorf("查询活动 %s 出错 %v", key, err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	if len(records) == 0 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "没有找到活动 " + key})
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIEvent(records[0]))
+}
+
+// apiRuns 最近的运行结果，支持的参数：source、limit
+func (s *Server) apiRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "只支持 GET 请求"})
+		return
+	}
+	limit := defaultAPILimit
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= maxAPILimit {
+		limit = v
+	}
+	runs, err := s.store.RecentRuns(r.URL.Query().Get("source"), limit)
+	if err != nil {
+		log.Logger.Errorf("查询运行结果出错 %v", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	resp := make([]*apiRun, 0, len(runs))
+	for _, run := range runs {
+		resp = append(resp, &apiRun{
+			ID:         run.ID,
+			Source:     run.Source,
+			StartedAt:  run.StartedAt,
+			FinishedAt: run.FinishedAt,
+			Found:      run.Found,
+			Filtered:   run.Filtered,
+			Notified:   run.Notified,
+			Seen:       run.Seen,
+			Events:     run.Keys(),
+			Problems:   run.ProblemList(),
+			Error:      run.Error,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func toAPIEvent(record *db.EventRecord) *apiEvent {
+	e := &apiEvent{Key: record.Key, Status: record.Status, Event: record.Event}
+	if !record.UpdatedAt.IsZero() {
+		e.UpdatedAt = &record.UpdatedAt
+	}
+	return e
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, utils.Location)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Logger.Errorf("返回 JSON 出错 %v", err)
+	}
+}
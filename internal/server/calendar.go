@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"show-live/pkg/db"
+	"show-live/pkg/ical"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+// calendarKeepDays 日历中保留结束了多少天以内的活动
+const calendarKeepDays = 30
+
+// calendar 可订阅的日历，包含已推送和要去的活动，?status=going 时只包含要去的活动
+func (s *Server) calendar(w http.ResponseWriter, r *http.Request) {
+	name := "演出"
+	statuses := []string{db.EventPushed, db.EventGoing}
+	if r.URL.Query().Get("status") == "going" {
+		name = "要去的演出"
+		statuses = []string{db.EventGoing}
+	}
+	events, err := s.store.Events(statuses...)
+	if err != nil {
+		log.Logger.Errorf("获取日历中的活动出错 %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	after := time.Now().AddDate(0, 0, -calendarKeepDays)
+	kept := make([]*utils.Event, 0, len(events))
+	for _, e := range events {
+		end := e.End
+		if end.IsZero() {
+			end = e.Start
+		}
+		if !e.Start.IsZero() && end.After(after) {
+			kept = append(kept, e)
+		}
+	}
+	utils.SortByStart(kept)
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Write(ical.Calendar(name, kept))
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+const pageSize = 50
+
+//go:embed templates/*.html
+var templates embed.FS
+
+var dashboardTemplate = template.Must(template.New("dashboard.html").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.In(utils.Location).Format("2006-01-02 15:04")
+	},
+}).ParseFS(templates, "templates/dashboard.html"))
+
+// statuses 页面中可以筛选的状态
+var statuses = []string{db.EventPushed, db.EventGoing, db.EventPending, db.EventUndelivered, db.EventNotInterested, db.Evenet404, db.EvenetErrorWhenRequest}
+
+// actions 页面中可以修改为的状态
+var actions = []string{db.EventGoing, db.EventNotInterested, db.EventPushed}
+
+// sources 页面中可以筛选的来源平台
+var sources = []string{"showstart", "simullink", "zhengzai"}
+
+type dashboardData struct {
+	Query    url.Values
+	Statuses []string
+	Actions  []string
+	Sources  []string
+	Records  []*db.EventRecord
+	Total    int
+	Page     int
+	Prev     string
+	Next     string
+	Error    string
+}
+
+// dashboard 按状态、来源平台、城市、演出日期浏览和搜索活动，默认只显示已推送和要去的活动
+func (s *Server) dashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	q := db.EventQuery{
+		Source: query.Get("source"),
+		City:   query.Get("city"),
+		Search: query.Get("q"),
+		Limit:  pageSize,
+	}
+	switch status := query.Get("status"); status {
+	case "":
+		q.Statuses = []string{db.EventPushed, db.EventGoing}
+	case "all":
+	default:
+		q.Statuses = []string{status}
+	}
+	data := &dashboardData{
+		Query:    query,
+		Statuses: statuses,
+		Actions:  actions,
+		Sources:  sources,
+		Page:     1,
+	}
+	if from, err := time.ParseInLocation("2006-01-02", query.Get("from"), utils.Location); err == nil {
+		q.From = from
+	}
+	if to, err := time.ParseInLocation("2006-01-02", query.Get("to"), utils.Location); err == nil {
+		// 包含结束日期当天
+		q.To = to.AddDate(0, 0, 1)
+	}
+	if page, err := strconv.Atoi(query.Get("page")); err == nil && page > 1 {
+		data.Page = page
+	}
+	q.Offset = (data.Page - 1) * pageSize
+	records, total, err := s.store.QueryEvents(q)
+	if err != nil {
+		log.Logger.Errorf("查询活动出错 %v", err)
+		data.Error = err.Error()
+	}
+	data.Records, data.Total = records, total
+	if data.Page > 1 {
+		data.Prev = pageURL(query, data.Page-1)
+	}
+	if data.Page*pageSize < total {
+		data.Next = pageURL(query, data.Page+1)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, data); err != nil {
+		log.Logger.Errorf("渲染页面出错 %v", err)
+	}
+}
+
+func pageURL(query url.Values, page int) string {
+	v := url.Values{}
+	for k, values := range query {
+		v[k] = values
+	}
+	v.Set("page", strconv.Itoa(page))
+	return "/?" + v.Encode()
+}
+
+// setStatus 修改活动的状态，完成后返回之前的页面
+func (s *Server) setStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key, name, status := r.FormValue("key"), r.FormValue("name"), r.FormValue("status")
+	if !contains(actions, status) {
+		http.Error(w, "不支持的状态 "+status, http.StatusBadRequest)
+		return
+	}
+	if err := s.store.SetStatus(key, name, status); err != nil {
+		log.Logger.Errorf("修改活动 %s 的状态为 %s 出错 %v", key, status, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Logger.Infof("活动 %s 的状态已修改为 %s", name, status)
+	back := r.FormValue("back")
+	if u, err := url.Parse(back); err != nil || u.Host != "" || u.Scheme != "" || back == "" {
+		back = "/"
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"show-live/pkg/atom"
+	"show-live/pkg/log"
+)
+
+const (
+	// FeedTitle Atom 订阅的标题
+	FeedTitle = "最新演出"
+	// DefaultFeedLimit Atom 订阅中默认的活动个数
+	DefaultFeedLimit = 50
+	maxFeedLimit     = 500
+)
+
+// feed 最近发现的活动的 Atom 订阅，?limit= 指定活动个数
+func (s *Server) feed(w http.ResponseWriter, r *http.Request) {
+	limit := DefaultFeedLimit
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= maxFeedLimit {
+		limit = v
+	}
+	events, err := s.store.RecentEvents(limit)
+	if err != nil {
+		log.Logger.Errorf("获取订阅中的活动出错 %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	b, err := atom.Feed(FeedTitle, scheme+"://"+r.Host+r.URL.RequestURI(), events)
+	if err != nil {
+		log.Logger.Errorf("生成订阅出错 %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", atom.ContentType)
+	w.Write(b)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"show-live/internal/action"
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+// Store 服务使用的活动数据，由守护进程实现
+type Store interface {
+	// Events 返回处于这些状态的活动
+	Events(statuses ...string) ([]*utils.Event, error)
+	// RecentEvents 返回最近发现的 limit 个活动
+	RecentEvents(limit int) ([]*db.Discovered, error)
+	// QueryEvents 按条件查询活动
+	QueryEvents(q db.EventQuery) ([]*db.EventRecord, int, error)
+	// SetStatus 修改活动的状态
+	SetStatus(key, name, status string) error
+	// Mute 屏蔽艺人或场地
+	Mute(kind, value string) error
+	// RecentRuns 返回最近的运行结果
+	RecentRuns(source string, limit int) ([]*db.Run, error)
+}
+
+// Server 守护进程的 HTTP 服务，提供浏览活动的页面、只读的 JSON 接口、日历订阅和 Atom 订阅，只应在局域网中使用
+type Server struct {
+	store  Store
+	signer *action.Signer
+	srv    *http.Server
+}
+
+// New signer 为空时不处理通知邮件中的操作链接
+func New(addr string, store Store, signer *action.Signer) *Server {
+	s := &Server{store: store, signer: signer}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.dashboard)
+	mux.HandleFunc("/events/status", s.setStatus)
+	mux.HandleFunc(action.Path, s.action)
+	mux.HandleFunc("/api/events", s.apiEvents)
+	mux.HandleFunc("/api/events/", s.apiEvent)
+	mux.HandleFunc("/api/runs", s.apiRuns)
+	mux.HandleFunc("/calendar.ics", s.calendar)
+	mux.HandleFunc("/feed.atom", s.feed)
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Start 在后台启动服务，监听失败时返回错误
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Logger.Errorf("HTTP 服务出错 %v", err)
+		}
+	}()
+	log.Logger.Infof("HTTP 服务已启动，监听 %s，日历订阅地址为 webcal://<局域网地址>%s/calendar.ics", s.srv.Addr, port(s.srv.Addr))
+	return nil
+}
+
+// Stop 等待正在处理的请求结束后关闭服务
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
+
+func port(addr string) string {
+	_, p, err := net.SplitHostPort(addr)
+	if err != nil || p == "" || p == "80" {
+		return ""
+	}
+	return ":" + p
+}
@@ -0,0 +1,83 @@
+package showstart
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"show-live/pkg/log"
+)
+
+const (
+	defaultDumpDir = "dumps"
+	// maxDumpsPerRun 每次运行最多保存多少个异常的页面，页面结构变化时通常所有页面都会异常
+	maxDumpsPerRun = 5
+)
+
+// health 最近一次运行时活动列表中的活动数和发现的异常，活动详情是并发请求的，需要加锁
+type health struct {
+	lock     sync.Mutex
+	seen     int
+	problems []string
+	// notDumped 超过保存上限、没有保存的异常页面数
+	notDumped int
+}
+
+func (c *ShowStart) resetHealth() {
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	c.health.seen = 0
+	c.health.problems = nil
+	c.health.notDumped = 0
+}
+
+func (c *ShowStart) addSeen(n int) {
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	c.health.seen += n
+}
+
+func (c *ShowStart) Seen() int {
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	return c.health.seen
+}
+
+func (c *ShowStart) Problems() []string {
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	problems := append([]string{}, c.health.problems...)
+	if c.health.notDumped > 0 {
+		problems = append(problems, fmt.Sprintf("还有 %d 个页面解析异常，没有保存", c.health.notDumped))
+	}
+	return problems
+}
+
+// report 记录页面解析的异常，并将页面保存到 DumpDir 下，文件名中带有时间和 name
+func (c *ShowStart) report(msg, name string, body []byte) {
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	log.Logger.Errorf("秀动%s", msg)
+	if len(c.health.problems) >= maxDumpsPerRun {
+		c.health.notDumped++
+		return
+	}
+	dir := c.DumpDir
+	if dir == "" {
+		dir = defaultDumpDir
+	}
+	file := filepath.Join(dir, fmt.Sprintf("showstart-%s-%s.html", time.Now().Format("20060102-150405"), name))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Logger.Errorf("创建目录 %s 出错 %v", dir, err)
+		c.health.problems = append(c.health.problems, msg)
+		return
+	}
+	if err := os.WriteFile(file, body, 0644); err != nil {
+		log.Logger.Errorf("保存页面到 %s 出错 %v", file, err)
+		c.health.problems = append(c.health.problems, msg)
+		return
+	}
+	c.health.problems = append(c.health.problems, fmt.Sprintf("%s，页面已保存到 %s", msg, file))
+}
@@ -0,0 +1,108 @@
+package showstart
+
+import (
+	"sync"
+
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+// fetched 请求活动的结果，请求过程中不写数据库
+type fetched struct {
+	id int64
+	// skipped 为 true 时活动不需要请求，status 为活动在数据库中的状态
+	skipped bool
+	status  string
+	e       *utils.Event
+	err     error
+}
+
+// checked 检查活动的结果，e 为需要通知的活动，没有则为 nil
+type checked struct {
+	id     int64
+	e      *utils.Event
+	status string
+	err    error
+}
+
+// parallel 用 workers 个协程对 0 到 n-1 执行 fn，fn 需要把结果写到调用方按下标准备好的位置，这样结果的顺序与并发无关
+func parallel(workers, n int, fn func(i int)) {
+	if workers <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	if workers > n {
+		workers = n
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+func (c *ShowStart) workers() int {
+	if c.Workers <= 0 {
+		return 1
+	}
+	return c.Workers
+}
+
+// fetchEvents 先依次读取活动在数据库中的状态，再并发请求还未推送过的活动，返回的结果与 ids 的顺序一致。
+// 本次运行中已经检查过的活动不再请求，使用检查时的状态
+func (c *ShowStart) fetchEvents(ids []int64) []*fetched {
+	results := make([]*fetched, len(ids))
+	toFetch := make([]*fetched, 0, len(ids))
+	for i, id := range ids {
+		r := &fetched{id: id}
+		results[i] = r
+		if status, ok := c.checked[id]; ok {
+			r.skipped = true
+			r.status = status
+			continue
+		}
+		keyInDB := eventKeyInDB(id)
+		value, err := c.d.GetValue(keyInDB)
+		if err != nil {
+			log.Logger.Errorf("检查键 %s 是否在数据库中存在时出错 %v", keyInDB, err)
+			r.skipped = true
+			continue
+		}
+		if value == db.EventPushed || value == db.EventGoing || value == db.EventPending || value == db.EventUndelivered || value == db.EventNotInterested {
+			r.skipped = true
+			r.status = value
+			continue
+		}
+		toFetch = append(toFetch, r)
+	}
+	parallel(c.workers(), len(toFetch), func(i int) {
+		r := toFetch[i]
+		r.e, r.err = c.requestEvent(eventURL(r.id))
+	})
+	return results
+}
+
+// checkEvents 请求数据库中还未推送过的活动，并按 ids 的顺序将不需要通知的结果写入数据库，
+// 数据库的写入顺序与逐个请求时相同。checkCity 为 true 时会检查活动是否在配置的城市中
+func (c *ShowStart) checkEvents(ids []int64, checkCity bool) []checked {
+	results := make([]checked, 0, len(ids))
+	for _, r := range c.fetchEvents(ids) {
+		e, status, err := c.apply(r, checkCity)
+		results = append(results, checked{id: r.id, e: e, status: status, err: err})
+	}
+	return results
+}
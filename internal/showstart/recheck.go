@@ -0,0 +1,85 @@
+package showstart
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+// recheck 重新请求数据库中状态为404或请求出错的活动，秀动的活动经常先返回404，过一段时间才上线。
+// 每次最多检查 Max404CountToCheck 个ID最大的活动，只检查第一次出错的时间在 RecheckMaxAge 之内的活动，
+// 并且只检查ID不小于最大活动ID减去 RecheckIDRange 的活动，秀动的活动ID是递增的，ID越小的活动发布得越早
+func (c *ShowStart) recheck() ([]*utils.Event, string) {
+	events := make([]*utils.Event, 0)
+	var errMsg string
+	maxID, err := c.maxEventID()
+	if err != nil {
+		log.Logger.Errorf("获取最大活动ID出错 %v", err)
+		return events, ""
+	}
+	ids := make([]int64, 0)
+	for _, status := range []string{db.Evenet404, db.EvenetErrorWhenRequest} {
+		keys, err := c.failedKeys(status)
+		if err != nil {
+			log.Logger.Errorf("获取状态为 %s 的活动出错 %v", status, err)
+			continue
+		}
+		for _, key := range keys {
+			id, ok := eventIDOfKey(key)
+			if !ok {
+				continue
+			}
+			// 比最大活动ID大的活动由 sweep 负责
+			if maxID > 0 && id > maxID {
+				continue
+			}
+			if c.RecheckIDRange > 0 && id < maxID-c.RecheckIDRange {
+				continue
+			}
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	if int64(len(ids)) > c.Max404CountToCheck {
+		ids = ids[:c.Max404CountToCheck]
+	}
+	log.Logger.Infof("重新检查 %d 个404或请求出错的活动", len(ids))
+	for _, r := range c.checkEvents(ids, true) {
+		if r.err != nil {
+			errMsg += fmt.Sprintf("重新请求演出报错，ID：%d，错误：%v\n", r.id, r.err)
+			continue
+		}
+		if r.e != nil {
+			log.Logger.Infof("活动 %d 之前请求不到，现在已经上线了", r.id)
+			events = append(events, r.e)
+		}
+	}
+	return events, errMsg
+}
+
+// failedKeys 返回状态为 status 的活动的键，配置了 RecheckMaxAge 并且数据库记录了出错的时间时只返回最近出错的
+func (c *ShowStart) failedKeys(status string) ([]string, error) {
+	if f, ok := c.d.(db.Failures); ok && c.RecheckMaxAge > 0 {
+		return f.FailedSince(status, time.Now().Add(-c.RecheckMaxAge))
+	}
+	return c.d.GetEventByValue(status)
+}
+
+// eventIDOfKey 从数据库键中解析秀动的活动ID，不是秀动的键返回 false
+func eventIDOfKey(key string) (int64, bool) {
+	prefix := utils.EventKey(sourceName, "")
+	if !strings.HasPrefix(key, prefix) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(key, prefix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -1,44 +1,117 @@
 package showstart
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"net/http"
+	"io"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 
+	"show-live/config"
 	"show-live/pkg/db"
+	"show-live/pkg/http"
 	"show-live/pkg/log"
 	"show-live/utils"
 )
 
 type ShowStart struct {
 	d                    db.DB
-	includeLabels        []string
 	cityCode             []int
 	otherCityInAfternoon []string
 	MaxNotFoundCount     int64
 	Max404CountToCheck   int64
+	// InitialEventID 数据库中没有最大活动ID时，从该ID开始向后查找
+	InitialEventID int64
+	// Cities 按活动ID查找时只保留这些城市的活动，如 上海
+	Cities []string
+	// RecheckIDRange 重新检查404活动时，只检查ID不小于最大活动ID减去该值的活动，为0时不限制
+	RecheckIDRange int64
+	// RecheckMaxAge 重新检查404活动时，只检查第一次404或请求出错的时间在该时长之内的活动，为0时不限制
+	RecheckMaxAge time.Duration
+	// TrackChanges 为 true 时重新请求城市活动列表中已推送过的活动，用于发现票价、时间等变化
+	TrackChanges bool
+	// Workers 并发请求活动列表和活动详情的数量，小于等于1时逐个请求
+	Workers int
+	// DumpDir 页面解析异常时保存页面的目录
+	DumpDir string
+	// limiter 限制同一个域名同时进行的请求数
+	limiter *http.HostLimiter
+	health  health
+	// known 最近一次运行时重新请求到的已推送过的活动
+	known []*utils.Event
+	// checked 本次运行中已经检查过的活动的状态，重新检查、城市活动列表和按活动ID查找中的同一个活动只请求一次
+	checked map[int64]string
 }
 
-func NewShowStartGeter(d db.DB, tags []string, city []int) *ShowStart {
+func NewShowStartGeter(d db.DB, city []int) *ShowStart {
 	return &ShowStart{
-		d:             d,
-		includeLabels: tags,
-		cityCode:      city,
+		d:        d,
+		cityCode: city,
 	}
 }
 
-const EventPushed = "已推送"
-const EventNotInterested = "不感兴趣"
-const Evenet404 = "404"
-const EvenetErrorWhenRequest = "请求活动时报错"
+// NewShowStartGeterWithConfig 根据配置创建秀动的活动获取器
+func NewShowStartGeterWithConfig(d db.DB, conf config.ShowStartSource) *ShowStart {
+	c := NewShowStartGeter(d, conf.CityCode)
+	c.Cities = conf.City
+	c.InitialEventID = conf.InitialEventID
+	c.MaxNotFoundCount = conf.MaxNotFoundCount
+	c.Max404CountToCheck = conf.Max404CountToCheck
+	c.RecheckIDRange = conf.RecheckIDRange
+	if conf.RecheckMaxAge != "" {
+		age, err := time.ParseDuration(conf.RecheckMaxAge)
+		if err != nil {
+			log.Logger.Errorf("recheck_max_age 格式错误 %v，不限制重新检查的时间", err)
+		}
+		c.RecheckMaxAge = age
+	}
+	c.TrackChanges = conf.TrackChanges
+	c.Workers = conf.Workers
+	c.DumpDir = conf.DumpDir
+	maxPerHost := conf.MaxPerHost
+	if maxPerHost <= 0 {
+		maxPerHost = conf.Workers
+	}
+	if maxPerHost > 0 {
+		c.limiter = http.NewHostLimiter(maxPerHost)
+	}
+	return c
+}
+
+const sourceName = "showstart"
+
+// defaultExclude 没有配置秀动的过滤规则时默认排除的活动，与以前写死在代码中的规则相同
+var defaultExclude = []config.Rule{{Title: "夜猫俱乐部|【JZ Club】"}}
+
+// Filter 返回秀动的过滤规则，没有配置任何规则时使用默认的 exclude 规则
+func Filter(conf config.Filter) config.Filter {
+	if len(conf.Include) == 0 && len(conf.Exclude) == 0 {
+		conf.Exclude = defaultExclude
+	}
+	return conf
+}
+
+func (c *ShowStart) Name() string {
+	return sourceName
+}
+
+func (c *ShowStart) DisplayName() string {
+	return "秀动"
+}
+
+// NotifyEmpty 秀动没有新活动时也发送通知，用来确认服务在正常运行
+func (c *ShowStart) NotifyEmpty() bool {
+	return true
+}
 
 func eventKeyInDB(id int64) string {
-	return fmt.Sprintf("showstart_eventid_%d", id)
+	return utils.EventKey(sourceName, strconv.FormatInt(id, 10))
 }
 
 func eventURL(id int64) string {
@@ -47,6 +120,8 @@ func eventURL(id int64) string {
 }
 
 func fillEventURL(id int64, e *utils.Event) {
+	e.Source = sourceName
+	e.ID = strconv.FormatInt(id, 10)
 	e.WebURL = fmt.Sprintf("https://www.showstart.com/event/%d", id)
 	e.WebViewURL = fmt.Sprintf("https://wap.showstart.com/pages/activity/detail/detail?activityId=%d", id)
 }
@@ -54,131 +129,286 @@ func fillEventURL(id int64, e *utils.Event) {
 func (c *ShowStart) GetEventsToNotify() ([]*utils.Event, error) {
 	pageSize := 20
 	events := make([]*utils.Event, 0)
-	page := 0
 	var errMsg string
-	fmt.Println(".........c.cityCode.......", len(c.cityCode))
+	knownIDs := make([]int64, 0)
+	c.resetHealth()
+	c.checked = make(map[int64]string)
+	if c.Max404CountToCheck > 0 {
+		recheckEvents, recheckErrMsg := c.recheck()
+		events = append(events, recheckEvents...)
+		errMsg += recheckErrMsg
+	}
 	for _, city := range c.cityCode {
-		for {
-			page++
-			eventIDs, err := c.requestEventList(page, pageSize, city)
-			if err != nil {
-				log.Logger.Errorf("请求城市 %d 的第 %d 出错 %v", city, page, err)
+		ids := c.requestCityEventIDs(city, pageSize)
+		c.addSeen(len(ids))
+		for _, r := range c.checkEvents(ids, false) {
+			if r.err != nil {
+				errMsg += fmt.Sprintf("请求演出报错，ID：%d，错误：%v\n", r.id, r.err)
 				continue
 			}
-			if len(eventIDs) == 0 {
-				break
+			if r.e != nil {
+				events = append(events, r.e)
+			} else if r.status == db.EventPushed || r.status == db.EventGoing {
+				knownIDs = append(knownIDs, r.id)
 			}
-			for _, eventID := range eventIDs {
-				keyInDB := eventKeyInDB(eventID)
-				value, err := c.d.GetValue(keyInDB)
-				if err != nil {
-					log.Logger.Errorf("检查键 %s 是否在数据库中存在时出错 %v", keyInDB, err)
-					continue
-				}
-
-				if value == EventPushed || value == EventNotInterested {
-					continue
-				}
-				e, err := c.requestEvent(eventURL(eventID))
-				name := "未知"
-				if e != nil {
-					name = e.Name
-				}
-				if err != nil {
-					if err == ErrorNotInterested {
-						c.d.SetKey(keyInDB, name, EventNotInterested)
-						continue
-					}
-					if err == Error404 {
-						c.d.SetKey(keyInDB, name, Evenet404)
-						continue
-					}
-					// 这个部分在出错的时候，返回错误内容，并在数据库里将活动标记为出错
-					errMsg += fmt.Sprintf("请求演出报错，ID：%d，错误：%v\n", eventID, err)
-					c.d.SetKey(keyInDB, name, EvenetErrorWhenRequest)
-					continue
-				}
-				c.d.SetKey(keyInDB, name, EventPushed)
-				fillEventURL(eventID, e)
-				events = append(events, e)
-			}
-
 		}
 	}
-
+	if c.MaxNotFoundCount > 0 {
+		sweepEvents, sweepErrMsg := c.sweep()
+		events = append(events, sweepEvents...)
+		errMsg += sweepErrMsg
+	}
+	c.known = make([]*utils.Event, 0)
+	if c.TrackChanges {
+		c.known = c.requestKnownEvents(knownIDs)
+	}
+	if errMsg != "" {
+		return events, fmt.Errorf("请求部分演出时出错：\n%s", strings.TrimSpace(errMsg))
+	}
 	return events, nil
 }
 
-var Error404 = errors.New("404")
-var ErrorNotInterested = errors.New("event is not interested")
+// apply 将请求活动的结果写入数据库，返回需要通知的活动（没有则为 nil）以及活动的状态，并记录到 checked 中。
+// checkCity 为 true 时会检查活动是否在配置的城市中，用于不是从城市活动列表中得到的活动ID
+func (c *ShowStart) apply(r *fetched, checkCity bool) (*utils.Event, string, error) {
+	if status, ok := c.checked[r.id]; ok && !r.skipped {
+		// 同一批活动ID中有重复时只处理第一个
+		return nil, status, nil
+	}
+	e, status, err := c.applyFetched(r, checkCity)
+	if c.checked != nil && !r.skipped {
+		if e != nil {
+			// 需要通知的活动本次运行中已经返回过，之后再遇到时按待推送处理
+			c.checked[r.id] = db.EventPending
+		} else {
+			c.checked[r.id] = status
+		}
+	}
+	return e, status, err
+}
 
-var maxRetryTimes = 5
+func (c *ShowStart) applyFetched(r *fetched, checkCity bool) (*utils.Event, string, error) {
+	if r.skipped {
+		return nil, r.status, nil
+	}
+	keyInDB := eventKeyInDB(r.id)
+	e, err := r.e, r.err
+	name := "未知"
+	if e != nil {
+		name = e.Name
+	}
+	if err == nil && checkCity && !c.inCities(e) {
+		err = ErrorNotInterested
+	}
+	if err != nil {
+		if err == ErrorNotInterested {
+			c.d.SetKey(keyInDB, name, db.EventNotInterested)
+			return nil, db.EventNotInterested, nil
+		}
+		if err == Error404 {
+			c.d.SetKey(keyInDB, name, db.Evenet404)
+			return nil, db.Evenet404, nil
+		}
+		// 这个部分在出错的时候，返回错误内容，并在数据库里将活动标记为出错
+		c.d.SetKey(keyInDB, name, db.EvenetErrorWhenRequest)
+		return nil, db.EvenetErrorWhenRequest, err
+	}
+	// 需要通知的活动由 pipeline 在通知送达后标记为已推送
+	fillEventURL(r.id, e)
+	return e, db.EventPushed, nil
+}
 
-func (c *ShowStart) requestEvent(url string) (*utils.Event, error) {
-	var retryTimes = 0
-	var res *http.Response
-	for {
-		// time.Sleep(time.Second)
-		retryTimes++
-		var err error
-		res, err = http.Get(url)
+func (c *ShowStart) KnownEvents() []*utils.Event {
+	return c.known
+}
+
+// requestKnownEvents 并发重新请求已推送过的活动的最新信息，结果与 ids 的顺序一致
+func (c *ShowStart) requestKnownEvents(ids []int64) []*utils.Event {
+	fetched := make([]*utils.Event, len(ids))
+	parallel(c.workers(), len(ids), func(i int) {
+		e, err := c.requestEvent(eventURL(ids[i]))
 		if err != nil {
-			return nil, err
+			log.Logger.Errorf("重新请求已推送的活动 %d 出错 %v", ids[i], err)
+			return
 		}
-		defer res.Body.Close()
-		if res.StatusCode == 200 {
-			break
-		}
-		if res.StatusCode != 200 {
-			if res.StatusCode == 404 {
-				return nil, Error404
-			}
-			if retryTimes >= maxRetryTimes {
-				return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
-			}
+		fillEventURL(ids[i], e)
+		fetched[i] = e
+	})
+	events := make([]*utils.Event, 0, len(ids))
+	for _, e := range fetched {
+		if e != nil {
+			events = append(events, e)
 		}
-		if retryTimes >= maxRetryTimes {
-			break
+	}
+	return events
+}
+
+// inCities 检查活动场地是否在配置的城市中，没有配置城市时不做限制
+func (c *ShowStart) inCities(e *utils.Event) bool {
+	if len(c.Cities) == 0 {
+		return true
+	}
+	for _, city := range c.Cities {
+		if strings.Contains(e.City, city) {
+			return true
 		}
 	}
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	return false
+}
+
+var Error404 = errors.New("404")
+var ErrorNotInterested = errors.New("event is not interested")
+
+// requestEvent 请求活动详情页，出错、返回 5xx 或 429 时由 http.Default 退避重试
+func (c *ShowStart) requestEvent(url string) (*utils.Event, error) {
+	release := c.limiter.Acquire(url)
+	defer release()
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == 404 {
+		return nil, Error404
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body error: %v", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("new document of query error: %v", err)
 	}
 	prefix := "#__layout > section > main > div > div.product > div > div.describe > "
 	site := doc.Find(prefix + "p:nth-child(4) > a").Text()
+	city := doc.Find(prefix + "p:nth-child(4)").Text()
 	time := strings.TrimPrefix(doc.Find(prefix+"p:nth-child(2)").Text(), "演出时间：")
-	var found = false
-	labels := doc.Find(prefix + "div.label").Text()
-	for _, v := range c.includeLabels {
-		if strings.Contains(labels, v) {
-			found = true
+	title := doc.Find(prefix + "div.title").Text()
+	tags := make([]string, 0)
+	doc.Find(prefix + "div.label").Children().Each(func(i int, s *goquery.Selection) {
+		if t := strings.TrimSpace(s.Text()); t != "" {
+			tags = append(tags, t)
+		}
+	})
+	if len(tags) == 0 {
+		if t := strings.TrimSpace(doc.Find(prefix + "div.label").Text()); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	artist := doc.Find(prefix + "p:nth-child(3) > a").Text()
+	price := doc.Find("#__layout > section > main > div > div.product > div > div.buy > div.price-tags").Text()
+	cover, _ := doc.Find(`meta[property="og:image"]`).Attr("content")
+	if cover == "" {
+		cover, _ = doc.Find("#__layout > section > main > div > div.product img").First().Attr("src")
+	}
+	if strings.HasPrefix(cover, "//") {
+		cover = "https:" + cover
+	}
+	e := &utils.Event{
+		Name:     title,
+		Time:     time,
+		Artist:   artist,
+		Site:     site,
+		City:     cityOfSite(city),
+		Cover:    cover,
+		Tags:     tags,
+		SellTime: parseSaleTime(doc.Find("body").Text()),
+		Price:    price}
+	e.Start, e.End = utils.ParseEventTime(time)
+	if missing := missingFields(e); len(missing) != 0 {
+		c.report(fmt.Sprintf("活动页面 %s 解析不到%s", url, strings.Join(missing, "、")), "event-"+path.Base(url), body)
+	}
+	return e, nil
+}
+
+// missingFields 返回活动页面中解析不到的关键字段，页面结构变化时这些字段会为空
+func missingFields(e *utils.Event) []string {
+	missing := make([]string, 0, 3)
+	if strings.TrimSpace(e.Name) == "" {
+		missing = append(missing, "标题")
+	}
+	if strings.TrimSpace(e.Site) == "" {
+		missing = append(missing, "场地")
+	}
+	if strings.TrimSpace(e.Time) == "" {
+		missing = append(missing, "演出时间")
+	}
+	return missing
+}
+
+var saleTimeRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`(?:开售时间|开票时间|开抢时间)[：:\s]*(\d{4})[-./年](\d{1,2})[-./月](\d{1,2})日?\s*(\d{1,2}):(\d{2})`),
+	regexp.MustCompile(`(\d{4})[-./年](\d{1,2})[-./月](\d{1,2})日?\s*(\d{1,2}):(\d{2})\s*(?:开售|开票|开抢)`),
+}
+
+// parseSaleTime 从活动页面中解析开售时间，如 开售时间：2024.03.01 12:00，解析不到时返回零值
+func parseSaleTime(text string) time.Time {
+	for _, re := range saleTimeRegexps {
+		m := re.FindStringSubmatch(text)
+		if len(m) != 6 {
+			continue
+		}
+		v := make([]int, 5)
+		for i := range v {
+			v[i], _ = strconv.Atoi(m[i+1])
 		}
+		return time.Date(v[0], time.Month(v[1]), v[2], v[3], v[4], 0, 0, utils.Location)
 	}
+	return time.Time{}
+}
+
+var cityRegexp = regexp.MustCompile(`[\[【](.+?)[\]】]`)
 
-	if !found {
-		return &utils.Event{
-			Time: time}, ErrorNotInterested
+// cityOfSite 从演出场地一栏中提取城市，如 [上海]育音堂，提取不到时返回整栏内容
+func cityOfSite(s string) string {
+	s = strings.TrimSpace(s)
+	if matches := cityRegexp.FindStringSubmatch(s); len(matches) > 1 {
+		return matches[1]
 	}
-	title := doc.Find(prefix + "div.title").Text()
-	if strings.Contains(title, "夜猫俱乐部") || strings.Contains(title, "【JZ Club】") {
-		return &utils.Event{
-			Time: time}, ErrorNotInterested
+	return s
+}
+
+// maxListPageErrors 连续多少页活动列表请求出错时不再请求这个城市的后续页
+const maxListPageErrors = 3
+
+// requestCityEventIDs 请求城市的活动列表，每次并发请求 Workers 页，直到遇到没有活动的页，返回的活动ID与页的顺序一致
+func (c *ShowStart) requestCityEventIDs(city, pageSize int) []int64 {
+	batch := c.workers()
+	ids := make([]int64, 0)
+	errCount := 0
+	for page := 1; ; page += batch {
+		lists := make([][]int64, batch)
+		errs := make([]error, batch)
+		parallel(batch, batch, func(i int) {
+			lists[i], errs[i] = c.requestEventList(page+i, pageSize, city)
+		})
+		for i := range lists {
+			if errs[i] != nil {
+				log.Logger.Errorf("请求城市 %d 的第 %d 页出错 %v", city, page+i, errs[i])
+				errCount++
+				if errCount >= maxListPageErrors {
+					return ids
+				}
+				continue
+			}
+			errCount = 0
+			if len(lists[i]) == 0 {
+				return ids
+			}
+			ids = append(ids, lists[i]...)
+		}
 	}
-	artist := doc.Find(prefix + "p:nth-child(3) > a").Text()
-	price := doc.Find("#__layout > section > main > div > div.product > div > div.buy > div.price-tags").Text()
-	return &utils.Event{
-		Name:   title,
-		Time:   time,
-		Artist: artist,
-		Site:   site,
-		Price:  price}, nil
 }
 
 func (c *ShowStart) requestEventList(page, pageSize, cityCode int) ([]int64, error) {
 	url := fmt.Sprintf("https://www.showstart.com/event/list?pageNo=%d&pageSize=%d&cityCode=%d",
 		page, pageSize, cityCode)
-	fmt.Println("..........................", url)
+	log.Logger.Debugf("请求活动列表 %s", url)
+	release := c.limiter.Acquire(url)
+	defer release()
 	res, err := http.Get(url)
 	if err != nil {
 		return nil, err
@@ -187,7 +417,11 @@ func (c *ShowStart) requestEventList(page, pageSize, cityCode int) ([]int64, err
 	if res.StatusCode != 200 {
 		return nil, errors.New(fmt.Sprintf("%s return %d code", url, res.StatusCode))
 	}
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body error: %v", err)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("new document of query error: %v", err)
 	}
@@ -216,5 +450,8 @@ func (c *ShowStart) requestEventList(page, pageSize, cityCode int) ([]int64, err
 			}
 		}
 	})
+	if page == 1 && len(eventIDs) == 0 {
+		c.report(fmt.Sprintf("城市 %d 的活动列表第一页没有活动", cityCode), fmt.Sprintf("list-%d", cityCode), body)
+	}
 	return eventIDs, nil
 }
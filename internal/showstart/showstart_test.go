@@ -0,0 +1,17 @@
+package showstart
+
+import (
+	"testing"
+
+	"show-live/config"
+	"show-live/internal/source"
+	"show-live/internal/source/sourcetest"
+	"show-live/pkg/db"
+)
+
+func TestGolden(t *testing.T) {
+	dumps := t.TempDir()
+	sourcetest.Golden(t, func(d db.DB) source.Source {
+		return NewShowStartGeterWithConfig(d, config.ShowStartSource{CityCode: []int{21}, Workers: 1, DumpDir: dumps})
+	})
+}
@@ -0,0 +1,84 @@
+package showstart
+
+import (
+	"fmt"
+	"strconv"
+
+	"show-live/pkg/db"
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+// maxEventIDKey 数据库中保存已知最大活动ID的键
+const maxEventIDKey = "showstart_max_event_id"
+
+// maxSweepCountPerRun 单次运行最多查找的活动ID数量，避免接口异常时一直查找下去
+const maxSweepCountPerRun = 1000
+
+// sweep 从已知的最大活动ID开始逐个向后请求活动，连续 MaxNotFoundCount 个活动不存在时停止，
+// 用来发现已经发布但还没出现在城市活动列表中的活动，新的最大活动ID会保存到数据库
+func (c *ShowStart) sweep() ([]*utils.Event, string) {
+	events := make([]*utils.Event, 0)
+	var errMsg string
+	maxID, err := c.maxEventID()
+	if err != nil {
+		log.Logger.Errorf("获取最大活动ID出错 %v", err)
+		return events, ""
+	}
+	if maxID <= 0 {
+		log.Logger.Warn("数据库中没有最大活动ID，也没有配置 initial_event_id，跳过按活动ID查找")
+		return events, ""
+	}
+	log.Logger.Infof("从活动ID %d 开始向后查找活动", maxID+1)
+	var notFound int64
+	last := maxID + maxSweepCountPerRun
+	// 每次并发请求 Workers 个活动，再按ID顺序处理结果，停止后同一批中剩下的结果直接丢弃，与逐个请求时的结果相同
+	for start := maxID + 1; start <= last && notFound < c.MaxNotFoundCount; start += int64(c.workers()) {
+		ids := make([]int64, 0, c.workers())
+		for id := start; id < start+int64(c.workers()) && id <= last; id++ {
+			ids = append(ids, id)
+		}
+		for _, r := range c.fetchEvents(ids) {
+			if notFound >= c.MaxNotFoundCount {
+				break
+			}
+			e, status, err := c.apply(r, true)
+			if err != nil {
+				errMsg += fmt.Sprintf("请求演出报错，ID：%d，错误：%v\n", r.id, err)
+			}
+			// 请求出错时无法确定活动是否存在，同样计入不存在的次数，但不更新最大活动ID，下次运行会重新请求
+			if status == db.Evenet404 || status == db.EvenetErrorWhenRequest || status == "" {
+				notFound++
+				continue
+			}
+			notFound = 0
+			maxID = r.id
+			if e != nil {
+				events = append(events, e)
+			}
+		}
+	}
+	if err := c.d.SetKey(maxEventIDKey, "秀动最大活动ID", strconv.FormatInt(maxID, 10)); err != nil {
+		log.Logger.Errorf("保存最大活动ID %d 出错 %v", maxID, err)
+	}
+	log.Logger.Infof("按活动ID查找结束，最大活动ID为 %d", maxID)
+	return events, errMsg
+}
+
+func (c *ShowStart) maxEventID() (int64, error) {
+	v, err := c.d.GetValue(maxEventIDKey)
+	if err != nil {
+		return 0, err
+	}
+	if v == "" {
+		return c.InitialEventID, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("数据库中的最大活动ID %s 不是数字 %v", v, err)
+	}
+	if id < c.InitialEventID {
+		return c.InitialEventID, nil
+	}
+	return id, nil
+}
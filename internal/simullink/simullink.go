@@ -2,25 +2,27 @@ package simullink
 
 import (
 	"errors"
-	"fmt"
 	"strings"
 	"time"
 
 	"show-live/pkg/db"
 	"show-live/pkg/http"
 	"show-live/pkg/log"
+	"show-live/utils"
 )
 
 type SimullinkGetter struct {
 	d             db.DB
-	tags          []string
 	url, cityCode string
+	// known 最近一次运行时看到的已推送过的活动
+	known []*utils.Event
+	// seen 最近一次运行时接口返回的活动数
+	seen int
 }
 
-func NewSimullinkGetter(d db.DB, tags []string, url, cityCode string) *SimullinkGetter {
+func NewSimullinkGetter(d db.DB, url, cityCode string) *SimullinkGetter {
 	return &SimullinkGetter{
 		d:        d,
-		tags:     tags,
 		url:      url,
 		cityCode: cityCode,
 	}
@@ -41,7 +43,17 @@ type PageInfo struct {
 	Direction   string `json:"direction"`
 }
 
-func (c SimullinkGetter) GetEventsToNotify() ([]string, error) {
+const sourceName = "simullink"
+
+func (c *SimullinkGetter) Name() string {
+	return sourceName
+}
+
+func (c *SimullinkGetter) DisplayName() string {
+	return "同感"
+}
+
+func (c *SimullinkGetter) GetEventsToNotify() ([]*utils.Event, error) {
 	now := time.Now()
 	beginOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	end := beginOfDay.AddDate(1, 0, 0).Add(24 * time.Hour).Add(-time.Second)
@@ -62,27 +74,42 @@ func (c SimullinkGetter) GetEventsToNotify() ([]string, error) {
 		log.Logger.Error(msg)
 		return nil, errors.New(msg)
 	}
-	nameToBeginTime := make(map[string]int64, 0)
+	events := make([]*utils.Event, 0)
+	seen := make(map[string]bool)
+	// legacyKeys 旧版本以标题和场地作为键
+	legacyKeys := make(map[string]string)
 	for {
 		for _, d := range resp.Data.Items {
-			title := d.UI.Title.Text
-			if len(c.tags) != 0 {
-				foundTag := false
-				tag := d.UI.Line1.Text
-				for _, v := range c.tags {
-					if strings.Contains(tag, v) {
-						foundTag = true
-					}
-				}
-				if !foundTag {
-					continue
-				}
+			id := d.Extra.Series.ID
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			e := &utils.Event{
+				Source: sourceName,
+				ID:     id,
+				Name:   d.UI.Title.Text,
+				Start:  time.UnixMilli(d.Extra.Series.BeginTime).In(utils.Location),
 			}
+			e.Time = e.Start.Format("2006-01-02 15:04:05")
+			if d.Extra.Series.EndTime > d.Extra.Series.BeginTime {
+				e.End = time.UnixMilli(d.Extra.Series.EndTime).In(utils.Location)
+			}
+			legacyKeys[id] = sourceName + e.Name
 			if len(d.Extra.AllInstances) != 0 {
-				venueName := d.Extra.AllInstances[0].VenueName
-				title = title + "___地点：" + venueName
+				e.Site = d.Extra.AllInstances[0].VenueName
+				legacyKeys[id] += "___地点：" + e.Site
+			}
+			if tag := strings.TrimSpace(d.UI.Line1.Text); tag != "" {
+				e.Tags = []string{tag}
 			}
-			nameToBeginTime[title] = d.Extra.Series.BeginTime
+			if len(d.UI.Thumbnails) != 0 {
+				e.Cover = d.UI.Thumbnails[0].URL
+				if e.Cover == "" {
+					e.Cover = d.UI.Thumbnails[0].ResourceURL
+				}
+			}
+			events = append(events, e)
 		}
 		if resp.Data.PageInfo.HasNextPage == 0 {
 			break
@@ -104,21 +131,41 @@ func (c SimullinkGetter) GetEventsToNotify() ([]string, error) {
 			return nil, errors.New(msg)
 		}
 	}
-	result := make([]string, 0, len(nameToBeginTime))
-	for k, v := range nameToBeginTime {
-		keyInDB := "simullink" + k
-		exists, err := c.d.Exists(keyInDB)
+	result := make([]*utils.Event, 0, len(events))
+	c.known = make([]*utils.Event, 0)
+	c.seen = len(events)
+	for _, e := range events {
+		keyInDB := e.Key()
+		value, err := db.GetValueOrLegacy(c.d, keyInDB, legacyKeys[e.ID], e.Name)
 		if err != nil {
-			log.Logger.Errorf("check if %s exists in db error", keyInDB, err)
+			log.Logger.Errorf("check if %s exists in db error %v", keyInDB, err)
+			continue
 		}
-		if !exists {
-			result = append(result, fmt.Sprintf("%s___开始时间：%s", k, time.Unix(v/1000, 0).Format("2006-01-02 15:04:05")))
-			c.d.SetKey(keyInDB, time.Unix(v, 0))
+		switch value {
+		case "":
+			result = append(result, e)
+		case db.EventPushed, db.EventGoing:
+			c.known = append(c.known, e)
 		}
 	}
 	return result, nil
 }
 
+func (c *SimullinkGetter) KnownEvents() []*utils.Event {
+	return c.known
+}
+
+func (c *SimullinkGetter) Seen() int {
+	return c.seen
+}
+
+func (c *SimullinkGetter) Problems() []string {
+	if c.seen == 0 {
+		return []string{"接口返回的活动列表为空"}
+	}
+	return nil
+}
+
 type Resp struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
@@ -0,0 +1,15 @@
+package simullink
+
+import (
+	"testing"
+
+	"show-live/internal/source"
+	"show-live/internal/source/sourcetest"
+	"show-live/pkg/db"
+)
+
+func TestGolden(t *testing.T) {
+	sourcetest.Golden(t, func(d db.DB) source.Source {
+		return NewSimullinkGetter(d, "https://api.simullink.com", "156310000")
+	})
+}
@@ -0,0 +1,33 @@
+package source
+
+import "show-live/utils"
+
+// Source 演出信息来源平台，秀动、同感、正在现场都实现了该接口
+type Source interface {
+	// Name 平台标识，同时作为 utils.Event.Source 以及数据库键的前缀
+	Name() string
+	// DisplayName 平台的中文名称，用于通知标题
+	DisplayName() string
+	// GetEventsToNotify 获取平台上需要通知的新活动，返回的活动都带有稳定的ID。
+	// 只有部分请求出错时同时返回获取到的活动和错误，活动不为 nil 时仍然会被通知
+	GetEventsToNotify() ([]*utils.Event, error)
+}
+
+// Tracker 可以提供已推送活动最新信息的平台，用于发现已推送活动的票价、时间等变化
+type Tracker interface {
+	// KnownEvents 返回最近一次 GetEventsToNotify 时看到的、之前已推送过的活动的最新信息
+	KnownEvents() []*utils.Event
+}
+
+// Heartbeat 没有新活动时也发送一次通知的平台，用来确认服务在正常运行，没有实现该接口的平台没有新活动时不通知
+type Heartbeat interface {
+	NotifyEmpty() bool
+}
+
+// Checker 可以检查抓取结果是否正常的平台，用于发现页面结构或接口的变化
+type Checker interface {
+	// Seen 返回最近一次 GetEventsToNotify 时活动列表中的活动总数，包括已推送过的活动
+	Seen() int
+	// Problems 返回最近一次 GetEventsToNotify 时发现的异常，如活动列表的第一页为空、活动页面解析不到标题
+	Problems() []string
+}
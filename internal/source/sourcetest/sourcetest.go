@@ -0,0 +1,78 @@
+// Package sourcetest 回放 cmd/fixture 录制的各平台响应，检查解析结果是否与录制时相同
+package sourcetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"show-live/config"
+	"show-live/internal/source"
+	"show-live/pkg/db"
+	"show-live/pkg/http"
+	"show-live/pkg/log"
+)
+
+var update = flag.Bool("update", false, "用回放的解析结果更新 testdata/golden.json")
+
+const (
+	responsesDir = "testdata/responses"
+	goldenFile   = "testdata/golden.json"
+)
+
+// Golden 回放 testdata/responses 中录制的响应，newSource 创建的平台解析出的活动需要与 testdata/golden.json 相同。
+// 还没有录制响应时跳过，录制使用 cmd/fixture record；-update 时用本次结果更新 golden.json
+func Golden(t *testing.T, newSource func(d db.DB) source.Source) {
+	t.Helper()
+	if entries, err := os.ReadDir(responsesDir); err != nil || len(entries) == 0 {
+		t.Skipf("%s 中没有录制的响应，需要先运行 cmd/fixture record", responsesDir)
+	}
+	if log.Logger == nil {
+		log.Logger = logrus.New()
+		log.Logger.SetOutput(io.Discard)
+	}
+	old := http.Default
+	defer func() { http.Default = old }()
+	// 回放时不需要限速和代理
+	if err := http.Configure(config.HTTP{Rate: 1000, Replay: responsesDir}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := db.InitCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Exit()
+	s := newSource(d)
+	events, err := s.GetEventsToNotify()
+	if err != nil {
+		t.Fatalf("获取活动出错 %v", err)
+	}
+	if c, ok := s.(source.Checker); ok {
+		if problems := c.Problems(); len(problems) != 0 {
+			t.Errorf("抓取结果异常 %v", problems)
+		}
+	}
+	got, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.WriteFile(goldenFile, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("读取 %s 出错 %v，可以用 -update 生成", goldenFile, err)
+	}
+	if bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+		return
+	}
+	t.Errorf("解析结果与 %s 不同，确认符合预期后用 -update 更新，本次结果为：\n%s", goldenFile, got)
+}
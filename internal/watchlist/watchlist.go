@@ -0,0 +1,56 @@
+package watchlist
+
+import (
+	"strings"
+
+	"show-live/config"
+	"show-live/utils"
+)
+
+// Watchlist 关注的艺人列表
+type Watchlist struct {
+	artists []artist
+}
+
+type artist struct {
+	name  string
+	names []string
+}
+
+func New(conf []config.WatchArtist) *Watchlist {
+	w := &Watchlist{}
+	for _, a := range conf {
+		names := make([]string, 0, len(a.Aliases)+1)
+		for _, n := range append([]string{a.Name}, a.Aliases...) {
+			if n := normalize(n); n != "" {
+				names = append(names, n)
+			}
+		}
+		if len(names) != 0 {
+			w.artists = append(w.artists, artist{name: a.Name, names: names})
+		}
+	}
+	return w
+}
+
+// Match 返回活动的艺人或标题中出现的关注艺人，匹配时忽略大小写和空格
+func (w *Watchlist) Match(e *utils.Event) []string {
+	if w == nil {
+		return nil
+	}
+	text := normalize(e.Artist) + "\n" + normalize(e.Name)
+	matched := make([]string, 0)
+	for _, a := range w.artists {
+		for _, n := range a.names {
+			if strings.Contains(text, n) {
+				matched = append(matched, a.name)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), ""))
+}
@@ -9,11 +9,16 @@ import (
 	"show-live/pkg/db"
 	"show-live/pkg/http"
 	"show-live/pkg/log"
+	"show-live/utils"
 )
 
 type ZhengZaiGetter struct {
 	d           db.DB
 	url, adCode string
+	// known 最近一次运行时看到的已推送过的活动
+	known []*utils.Event
+	// seen 最近一次运行时接口返回的该城市的活动数
+	seen int
 }
 
 func NewZhengZaiGetterGetter(d db.DB, url, adCode string) *ZhengZaiGetter {
@@ -24,7 +29,17 @@ func NewZhengZaiGetterGetter(d db.DB, url, adCode string) *ZhengZaiGetter {
 	}
 }
 
-func (c ZhengZaiGetter) GetEventsToNotify() ([]string, error) {
+const sourceName = "zhengzai"
+
+func (c *ZhengZaiGetter) Name() string {
+	return sourceName
+}
+
+func (c *ZhengZaiGetter) DisplayName() string {
+	return "正在现场"
+}
+
+func (c *ZhengZaiGetter) GetEventsToNotify() ([]*utils.Event, error) {
 	url := fmt.Sprintf("%s/kylin/performance/localList?adCode=%s&days=0&orderBy=timeStart&sort=ASC",
 		c.url, c.adCode)
 	var resp Resp
@@ -36,30 +51,69 @@ func (c ZhengZaiGetter) GetEventsToNotify() ([]string, error) {
 		log.Logger.Error(msg)
 		return nil, errors.New(msg)
 	}
-	nameToBeginTime := make(map[string]string, 0)
+	result := make([]*utils.Event, 0)
+	c.known = make([]*utils.Event, 0)
+	c.seen = 0
 	for _, v := range resp.Data.List {
 		if strconv.FormatInt(v.CityID, 10) != c.adCode {
 			continue
 		}
-		title := v.Title + "___地点：" + v.FieldName
-		nameToBeginTime[title] = v.TimeEnd
-	}
-	result := make([]string, 0, len(nameToBeginTime))
-	for k, v := range nameToBeginTime {
-		keyInDB := "zhengzai" + k
-		exists, err := c.d.Exists(keyInDB)
+		c.seen++
+		e := &utils.Event{
+			Source: sourceName,
+			ID:     v.PerformancesID,
+			Name:   v.Title,
+			Time:   v.TimeStart,
+			Site:   v.FieldName,
+			Price:  v.Price,
+			Cover:  v.ImgPoster,
+		}
+		e.Start = parseTime(v.TimeStart)
+		e.End = parseTime(v.TimeEnd)
+		e.SellTime = parseTime(v.SellTime)
+		e.SellMemberTime = parseTime(v.SellMemberTime)
+		e.StopSellTime = parseTime(v.StopSellTime)
+		keyInDB := e.Key()
+		// 旧版本以标题和场地作为键
+		value, err := db.GetValueOrLegacy(c.d, keyInDB, sourceName+v.Title+"___地点："+v.FieldName, e.Name)
 		if err != nil {
-			log.Logger.Errorf("check if %s exists in db error", keyInDB, err)
+			log.Logger.Errorf("check if %s exists in db error %v", keyInDB, err)
+			continue
 		}
-		if !exists {
-			result = append(result, fmt.Sprintf("%s___开始时间：%s", k, v))
-			t, _ := time.Parse("2006-01-02 15:04:05", v)
-			c.d.SetKey(keyInDB, t)
+		switch value {
+		case "":
+			result = append(result, e)
+		case db.EventPushed, db.EventGoing:
+			c.known = append(c.known, e)
 		}
 	}
 	return result, nil
 }
 
+// parseTime 解析正在现场的时间，格式为 2006-01-02 15:04:05，解析失败时返回零值
+func parseTime(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, utils.Location)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func (c *ZhengZaiGetter) KnownEvents() []*utils.Event {
+	return c.known
+}
+
+func (c *ZhengZaiGetter) Seen() int {
+	return c.seen
+}
+
+func (c *ZhengZaiGetter) Problems() []string {
+	if c.seen == 0 {
+		return []string{"接口返回的活动列表为空"}
+	}
+	return nil
+}
+
 type Resp struct {
 	Code    string      `json:"code"`
 	Message interface{} `json:"message"`
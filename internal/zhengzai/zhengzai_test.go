@@ -0,0 +1,15 @@
+package zhengzai
+
+import (
+	"testing"
+
+	"show-live/internal/source"
+	"show-live/internal/source/sourcetest"
+	"show-live/pkg/db"
+)
+
+func TestGolden(t *testing.T) {
+	sourcetest.Golden(t, func(d db.DB) source.Source {
+		return NewZhengZaiGetterGetter(d, "https://kylin.zhengzai.tv", "310000")
+	})
+}
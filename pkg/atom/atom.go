@@ -0,0 +1,121 @@
+package atom
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"show-live/pkg/db"
+)
+
+// ContentType Atom 订阅的类型
+const ContentType = "application/atom+xml; charset=utf-8"
+
+type feed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Author  author   `xml:"author"`
+	Links   []link   `xml:"link,omitempty"`
+	Entries []entry  `xml:"entry"`
+}
+
+type author struct {
+	Name string `xml:"name"`
+}
+
+type link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type entry struct {
+	ID        string  `xml:"id"`
+	Title     string  `xml:"title"`
+	Published string  `xml:"published"`
+	Updated   string  `xml:"updated"`
+	Links     []link  `xml:"link,omitempty"`
+	Summary   string  `xml:"summary"`
+	Content   content `xml:"content"`
+}
+
+type content struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Feed 生成最近发现的活动的 Atom 订阅，self 为订阅自身的地址，可以为空
+func Feed(title, self string, events []*db.Discovered) ([]byte, error) {
+	f := feed{
+		ID:      "urn:show-live:feed",
+		Title:   title,
+		Updated: time.Now().Format(time.RFC3339),
+		Author:  author{Name: "show-live"},
+		Entries: make([]entry, 0, len(events)),
+	}
+	if self != "" {
+		f.ID = self
+		f.Links = append(f.Links, link{Href: self, Rel: "self"})
+	}
+	if len(events) != 0 {
+		f.Updated = latest(events).Format(time.RFC3339)
+	}
+	for _, d := range events {
+		e := d.Event
+		item := entry{
+			ID:        "urn:show-live:" + e.Key(),
+			Title:     e.Name,
+			Published: d.DiscoveredAt.Format(time.RFC3339),
+			Updated:   d.UpdatedAt.Format(time.RFC3339),
+			Summary:   fmt.Sprintf("%s %s %s", e.Time, e.Site, e.Price),
+			Content:   content{Type: "html", Body: body(d)},
+		}
+		if e.WebURL != "" {
+			item.Links = append(item.Links, link{Href: e.WebURL, Rel: "alternate"})
+		}
+		f.Entries = append(f.Entries, item)
+	}
+	b, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+func latest(events []*db.Discovered) time.Time {
+	var t time.Time
+	for _, d := range events {
+		if d.UpdatedAt.After(t) {
+			t = d.UpdatedAt
+		}
+	}
+	return t
+}
+
+// body 条目的 HTML 内容，包括封面、演出时间、场地、艺人、票价和活动链接
+func body(d *db.Discovered) string {
+	e := d.Event
+	var b strings.Builder
+	if e.Cover != "" {
+		fmt.Fprintf(&b, "<p><img src=\"%s\" width=\"240\"></p>", html.EscapeString(e.Cover))
+	}
+	for _, f := range []struct {
+		name, value string
+	}{
+		{"演出时间", e.Time},
+		{"场地", strings.TrimSpace(e.City + " " + e.Site)},
+		{"艺人", e.Artist},
+		{"票价", e.Price},
+	} {
+		if v := strings.TrimSpace(f.value); v != "" {
+			fmt.Fprintf(&b, "<p><strong>%s</strong>：%s</p>", f.name, html.EscapeString(v))
+		}
+	}
+	if e.WebURL != "" {
+		fmt.Fprintf(&b, "<p><a href=\"%s\">查看详情</a></p>", html.EscapeString(e.WebURL))
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package cover
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"show-live/pkg/http"
+	"show-live/utils"
+)
+
+const maxCoverSize = 20 << 20
+
+var extOfContentType = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Save 下载封面图片并保存到 dir 下，文件名为图片内容的 sha256，同一张图片只会保存一次，返回保存的文件路径。
+// 与请求平台共用 http.Default，同样限速、重试和熔断
+func Save(dir, url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("下载封面 %s 出错 %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("下载封面 %s 返回 %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverSize))
+	if err != nil {
+		return "", fmt.Errorf("读取封面 %s 出错 %v", url, err)
+	}
+	sum := sha256.Sum256(body)
+	file := filepath.Join(dir, hex.EncodeToString(sum[:])+ext(resp.Header.Get("Content-Type"), url))
+	exists, err := utils.PathExists(file)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return file, nil
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("创建封面目录 %s 出错 %v", dir, err)
+	}
+	if err := os.WriteFile(file, body, 0644); err != nil {
+		return "", fmt.Errorf("保存封面 %s 出错 %v", file, err)
+	}
+	return file, nil
+}
+
+// ext 根据 Content-Type 确定图片后缀，无法确定时使用链接中的后缀
+func ext(contentType, url string) string {
+	contentType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	if e, ok := extOfContentType[contentType]; ok {
+		return e
+	}
+	e := path.Ext(strings.Split(url, "?")[0])
+	if e == "" || len(e) > 5 {
+		return ".img"
+	}
+	return strings.ToLower(e)
+}
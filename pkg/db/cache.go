@@ -6,18 +6,29 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
-	"time"
+	"sort"
+	"sync"
 
 	"github.com/patrickmn/go-cache"
 
 	"show-live/utils"
 )
 
+const cacheType = "cache"
+
+// Cache 基于 JSON 文件的数据库，适合活动数量不多的平台，每次写入都会落盘
 type Cache struct {
 	file string
+	lock sync.Mutex
 	*cache.Cache
 }
 
+// record 缓存中保存的活动信息，与 sqlite 中 events 表的字段对应
+type record struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
 func InitCache(dir string) (*Cache, error) {
 	file := path.Join(dir, "cache.json")
 
@@ -25,21 +36,13 @@ func InitCache(dir string) (*Cache, error) {
 	if err != nil {
 		return nil, fmt.Errorf("check if dir exists error %v", err)
 	}
-	var c = cache.New(5*time.Minute, 10*time.Minute)
+	var c = cache.New(cache.NoExpiration, 0)
 	if !exists {
 		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
 			return nil, fmt.Errorf("mkdir error %v", err)
 		}
-	} else {
-		jsonItems, err := ioutil.ReadFile(file)
-		if err != nil {
-			return nil, fmt.Errorf("Error loading cache from file: %s", err)
-		}
-		items := make(map[string]cache.Item)
-		json.Unmarshal(jsonItems, &items)
-		for key, item := range items {
-			c.Set(key, item.Object, time.Duration(item.Expiration))
-		}
+	} else if err := loadCache(file, c); err != nil {
+		return nil, err
 	}
 	return &Cache{
 		file:  file,
@@ -47,11 +50,42 @@ func InitCache(dir string) (*Cache, error) {
 	}, nil
 }
 
-func (c *Cache) SetKey(key, name string, value interface{}) error {
-	c.Set(key, value, -1)
+func loadCache(file string, c *cache.Cache) error {
+	exists, err := utils.PathExists(file)
+	if err != nil {
+		return fmt.Errorf("check if file exists error %v", err)
+	}
+	if !exists {
+		return nil
+	}
+	jsonItems, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("Error loading cache from file: %s", err)
+	}
+	items := make(map[string]struct {
+		Object json.RawMessage
+	})
+	if err := json.Unmarshal(jsonItems, &items); err != nil {
+		return fmt.Errorf("Error parsing cache file %s: %s", file, err)
+	}
+	for key, item := range items {
+		var r record
+		if err := json.Unmarshal(item.Object, &r); err != nil || r.Status == "" {
+			// 旧版本的缓存只保存了活动时间，存在即视为已推送
+			r = record{Status: EventPushed}
+		}
+		c.Set(key, r, cache.NoExpiration)
+	}
 	return nil
 }
 
+func (c *Cache) SetKey(key, name, value string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.Set(key, record{Name: name, Status: value}, cache.NoExpiration)
+	return c.save()
+}
+
 func (c *Cache) Exists(key string) (bool, error) {
 	_, ok := c.Get(key)
 	if !ok {
@@ -60,24 +94,37 @@ func (c *Cache) Exists(key string) (bool, error) {
 	return true, nil
 }
 
-func (c *Cache) GetValue(key string) (interface{}, error) {
+func (c *Cache) GetValue(key string) (string, error) {
 	v, ok := c.Get(key)
 	if !ok {
-		return false, nil
+		return "", nil
 	}
-	return v, nil
+	return v.(record).Status, nil
 }
 
-func (c *Cache) GetEventByValue(value int64) ([]string, error) {
-	return nil, nil
+func (c *Cache) GetEventByValue(value string) ([]string, error) {
+	events := make([]string, 0)
+	for key, item := range c.Items() {
+		if item.Object.(record).Status == value {
+			events = append(events, key)
+		}
+	}
+	sort.Strings(events)
+	return events, nil
 }
 
 func (c *Cache) Exit() error {
-	items := c.Items()
-	jsonItems, _ := json.Marshal(items)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.save()
+}
 
-	err := ioutil.WriteFile(c.file, jsonItems, 0644)
+func (c *Cache) save() error {
+	jsonItems, err := json.Marshal(c.Items())
 	if err != nil {
+		return fmt.Errorf("Error marshaling cache: %s", err)
+	}
+	if err := ioutil.WriteFile(c.file, jsonItems, 0644); err != nil {
 		return fmt.Errorf("Error saving cache to file: %s", err)
 	}
 	return nil
@@ -1,5 +1,34 @@
 package db
 
+import "time"
+
+// 活动在数据库中的状态
+const (
+	EventPushed = "已推送"
+	// EventPending 活动已加入发件箱，等待通知渠道确认送达
+	EventPending = "待推送"
+	// EventUndelivered 活动在所有通知渠道的发件箱中都超过了最多尝试次数，不再自动推送
+	EventUndelivered   = "推送失败"
+	EventNotInterested = "不感兴趣"
+	// EventGoing 已推送的活动被标记为要去（已买票），会在演出前一天和当天提醒
+	EventGoing             = "要去"
+	Evenet404              = "404"
+	EvenetErrorWhenRequest = "请求活动时报错"
+)
+
+// failedStatuses 请求活动失败的状态，之后会重新检查
+var failedStatuses = []string{Evenet404, EvenetErrorWhenRequest}
+
+func isFailed(status string) bool {
+	return status == Evenet404 || status == EvenetErrorWhenRequest
+}
+
+// Failures 记录活动第一次404或请求出错的时间，目前只有 sqlite 实现了该接口
+type Failures interface {
+	// FailedSince 返回状态为 status、并且第一次404或请求出错的时间不早于 since 的活动的键
+	FailedSince(status string, since time.Time) ([]string, error)
+}
+
 type DB interface {
 	SetKey(key, name string, value string) error
 	Exists(key string) (bool, error)
@@ -7,3 +36,20 @@ type DB interface {
 	GetEventByValue(value string) ([]string, error)
 	Exit() error
 }
+
+// GetValueOrLegacy 获取活动的状态，没有时查找旧版本使用的键 legacyKey，
+// 旧版本中存在的活动都已推送过，找到时以新的键迁移为已推送，之后不再查找旧的键
+func GetValueOrLegacy(d DB, key, legacyKey, name string) (string, error) {
+	value, err := d.GetValue(key)
+	if err != nil || value != "" || legacyKey == "" {
+		return value, err
+	}
+	exists, err := d.Exists(legacyKey)
+	if err != nil || !exists {
+		return "", err
+	}
+	if err := d.SetKey(key, name, EventPushed); err != nil {
+		return "", err
+	}
+	return EventPushed, nil
+}
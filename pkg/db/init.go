@@ -0,0 +1,27 @@
+package db
+
+import (
+	"fmt"
+
+	"show-live/config"
+)
+
+// Init 根据配置初始化 sqlite 或 JSON 文件缓存数据库
+func Init(conf config.DB) (DB, error) {
+	switch conf.Type {
+	case sqliteType, "":
+		s, err := InitSqlite(conf.File)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case cacheType:
+		c, err := InitCache(conf.Dir)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库类型 %s", conf.Type)
+	}
+}
@@ -0,0 +1,45 @@
+package db
+
+import "time"
+
+// 屏蔽的类型
+const (
+	MuteArtist = "artist"
+	MuteVenue  = "venue"
+)
+
+// Mutes 通过通知中的链接屏蔽的艺人和场地，之后这些艺人和场地的活动不再通知。目前只有 sqlite 实现了该接口
+type Mutes interface {
+	Mute(kind, value string) error
+	Muted() ([]*Mute, error)
+}
+
+type Mute struct {
+	ID        uint   `gorm:"primarykey"`
+	Kind      string `gorm:"uniqueIndex:idx_mute"`
+	Value     string `gorm:"uniqueIndex:idx_mute"`
+	CreatedAt time.Time
+}
+
+func (*Mute) tableName() string {
+	return "mutes"
+}
+
+func (s *sqliteHandler) Mute(kind, value string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	m := &Mute{}
+	return s.db.Table(m.tableName()).
+		Where(Mute{Kind: kind, Value: value}).
+		FirstOrCreate(m).Error
+}
+
+func (s *sqliteHandler) Muted() ([]*Mute, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	mutes := make([]*Mute, 0)
+	if err := s.db.Table((&Mute{}).tableName()).Order("id").Find(&mutes).Error; err != nil {
+		return nil, err
+	}
+	return mutes, nil
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"show-live/utils"
+)
+
+// 发件箱中通知的状态
+const (
+	OutboxPending   = "待推送"
+	OutboxDelivered = "已推送"
+	OutboxFailed    = "推送失败"
+)
+
+// Outbox 持久化的通知发件箱，活动先进入发件箱，通知渠道确认送达后才标记为已推送，
+// 失败的通知在之后的运行中按指数退避重试。目前只有 sqlite 实现了该接口
+type Outbox interface {
+	// EnqueueOutbox 将活动加入某个通知渠道的发件箱，同一渠道中还未送达的活动不会重复加入
+	EnqueueOutbox(notifier string, e *utils.Event) error
+	// DueOutbox 返回来源平台在某个通知渠道中到了重试时间、还未送达的通知
+	DueOutbox(source, notifier string, now time.Time) ([]*OutboxItem, error)
+	// SaveOutbox 保存通知的推送结果
+	SaveOutbox(item *OutboxItem) error
+	// AllFailed 活动在所有通知渠道的发件箱中是否都推送失败
+	AllFailed(eventKey string) (bool, error)
+}
+
+type OutboxItem struct {
+	ID            uint   `gorm:"primarykey"`
+	Notifier      string `gorm:"index"`
+	Source        string `gorm:"index"`
+	EventKey      string `gorm:"index"`
+	Payload       string
+	CoverFile     string
+	Status        string `gorm:"index"`
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (*OutboxItem) tableName() string {
+	return "outbox"
+}
+
+func newOutboxItem(notifier string, e *utils.Event) (*OutboxItem, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxItem{
+		Notifier:      notifier,
+		Source:        e.Source,
+		EventKey:      e.Key(),
+		Payload:       string(payload),
+		CoverFile:     e.CoverFile,
+		Status:        OutboxPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
+
+// Event 还原加入发件箱时的活动信息
+func (o *OutboxItem) Event() (*utils.Event, error) {
+	var e utils.Event
+	if err := json.Unmarshal([]byte(o.Payload), &e); err != nil {
+		return nil, err
+	}
+	e.CoverFile = o.CoverFile
+	return &e, nil
+}
+
+func (s *sqliteHandler) EnqueueOutbox(notifier string, e *utils.Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item, err := newOutboxItem(notifier, e)
+	if err != nil {
+		return err
+	}
+	var exists bool
+	if err := s.db.Table(item.tableName()).
+		Select("count(*) > 0").
+		Where("notifier = ? AND event_key = ? AND status = ?", notifier, item.EventKey, OutboxPending).
+		Find(&exists).Error; err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return s.db.Table(item.tableName()).Create(item).Error
+}
+
+func (s *sqliteHandler) DueOutbox(source, notifier string, now time.Time) ([]*OutboxItem, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	items := make([]*OutboxItem, 0)
+	if err := s.db.Table((&OutboxItem{}).tableName()).
+		Where("source = ? AND notifier = ? AND status = ? AND next_attempt_at <= ?", source, notifier, OutboxPending, now).
+		Order("id").
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *sqliteHandler) SaveOutbox(item *OutboxItem) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.db.Table(item.tableName()).Save(item).Error
+}
+
+func (s *sqliteHandler) AllFailed(eventKey string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var counts struct {
+		Total  int
+		Failed int
+	}
+	if err := s.db.Table((&OutboxItem{}).tableName()).
+		Select("count(*) AS total, coalesce(sum(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) AS failed", OutboxFailed).
+		Where("event_key = ?", eventKey).
+		Scan(&counts).Error; err != nil {
+		return false, err
+	}
+	return counts.Total > 0 && counts.Failed == counts.Total, nil
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"show-live/utils"
+)
+
+// Querier 按条件查询数据库中的活动，目前只有 sqlite 实现了该接口
+type Querier interface {
+	// QueryEvents 返回满足条件的活动以及满足条件的活动总数，按最近一次快照的时间倒序
+	QueryEvents(q EventQuery) ([]*EventRecord, int, error)
+}
+
+// EventQuery 查询活动的条件，为空的条件不生效。
+// City、Tag、Venue、From、To 需要活动的快照，没有快照的活动（如 404、不感兴趣的活动）不会满足这些条件
+type EventQuery struct {
+	// Key 只查询这个活动
+	Key      string
+	Statuses []string
+	Source   string
+	City     string
+	// Tag 活动的任意一个标签
+	Tag string
+	// Venue 场地包含的文本
+	Venue string
+	// From、To 演出开始时间的范围
+	From, To time.Time
+	// Search 在活动名称、艺人、场地中搜索
+	Search string
+	// Offset、Limit 分页，Limit 为0时返回全部
+	Offset, Limit int
+}
+
+// EventRecord 数据库中的活动
+type EventRecord struct {
+	Key    string
+	Name   string
+	Status string
+	// Event 活动最近一次的快照，没有快照时为 nil
+	Event *utils.Event
+	// UpdatedAt 最近一次快照的时间，没有快照时为零值
+	UpdatedAt time.Time
+}
+
+type eventRow struct {
+	Event     string
+	Name      string
+	Status    string
+	Payload   *string
+	CreatedAt *time.Time
+}
+
+func (s *sqliteHandler) QueryEvents(q EventQuery) ([]*EventRecord, int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	query := s.db.Table("events AS e").
+		Joins("LEFT JOIN snapshots AS s ON s.id = (SELECT MAX(id) FROM snapshots WHERE event_key = e.event)")
+	if q.Key != "" {
+		query = query.Where("e.event = ?", q.Key)
+	}
+	if len(q.Statuses) != 0 {
+		query = query.Where("e.status IN ?", q.Statuses)
+	}
+	if q.Source != "" {
+		query = query.Where("e.event LIKE ?", utils.EventKey(q.Source, "%"))
+	}
+	if q.City != "" {
+		query = query.Where("json_extract(s.payload, '$.city') = ?", q.City)
+	}
+	if q.Tag != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM json_each(s.payload, '$.tags') WHERE json_each.value = ?)", q.Tag)
+	}
+	if q.Venue != "" {
+		query = query.Where("json_extract(s.payload, '$.site') LIKE ?", "%"+q.Venue+"%")
+	}
+	// 快照中的时间都是北京时间，RFC3339 格式的字符串可以直接比较大小
+	if !q.From.IsZero() {
+		query = query.Where("json_extract(s.payload, '$.start') >= ?", q.From.In(utils.Location).Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		query = query.Where("json_extract(s.payload, '$.start') < ?", q.To.In(utils.Location).Format(time.RFC3339)).
+			Where("json_extract(s.payload, '$.start') > ?", time.Time{}.Format(time.RFC3339))
+	}
+	if search := strings.TrimSpace(q.Search); search != "" {
+		like := "%" + search + "%"
+		query = query.Where("(e.name LIKE ? OR json_extract(s.payload, '$.artist') LIKE ? OR json_extract(s.payload, '$.site') LIKE ?)",
+			like, like, like)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	query = query.Select("e.event, e.name, e.status, s.payload, s.created_at").
+		Order("s.created_at IS NULL, s.created_at DESC, e.rowid DESC")
+	if q.Limit > 0 {
+		query = query.Offset(q.Offset).Limit(q.Limit)
+	}
+	rows := make([]*eventRow, 0)
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	records := make([]*EventRecord, 0, len(rows))
+	for _, r := range rows {
+		record := &EventRecord{Key: r.Event, Name: r.Name, Status: r.Status}
+		if r.Payload != nil {
+			var e utils.Event
+			if err := json.Unmarshal([]byte(*r.Payload), &e); err == nil {
+				record.Event = &e
+			}
+		}
+		if r.CreatedAt != nil {
+			record.UpdatedAt = *r.CreatedAt
+		}
+		records = append(records, record)
+	}
+	return records, int(total), nil
+}
+
+// Unfiltered 只保留不需要快照的条件，去掉分页，用于快照保存在其他数据库中的情况，之后再用 Match 过滤
+func (q EventQuery) Unfiltered() EventQuery {
+	return EventQuery{Key: q.Key, Statuses: q.Statuses, Source: q.Source}
+}
+
+// Match 活动是否满足需要快照的条件，与 QueryEvents 中的条件相同
+func (q EventQuery) Match(r *EventRecord) bool {
+	e := r.Event
+	if e == nil {
+		return q.City == "" && q.Tag == "" && q.Venue == "" && q.From.IsZero() && q.To.IsZero() &&
+			(strings.TrimSpace(q.Search) == "" || containsFold(r.Name, strings.TrimSpace(q.Search)))
+	}
+	if q.City != "" && e.City != q.City {
+		return false
+	}
+	if q.Tag != "" {
+		found := false
+		for _, t := range e.Tags {
+			if t == q.Tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Venue != "" && !containsFold(e.Site, q.Venue) {
+		return false
+	}
+	if !q.From.IsZero() && e.Start.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && (e.Start.IsZero() || !e.Start.Before(q.To)) {
+		return false
+	}
+	if search := strings.TrimSpace(q.Search); search != "" &&
+		!containsFold(r.Name, search) && !containsFold(e.Artist, search) && !containsFold(e.Site, search) {
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
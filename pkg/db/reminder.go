@@ -0,0 +1,110 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"show-live/utils"
+)
+
+// 提醒的状态
+const (
+	ReminderPending = "待提醒"
+	ReminderSent    = "已提醒"
+	ReminderExpired = "已过期"
+	// ReminderCanceled 活动不再需要提醒，如取消了要去
+	ReminderCanceled = "已取消"
+)
+
+// Reminders 保存未来某个时间需要发送的提醒，如开售提醒。目前只有 sqlite 实现了该接口
+type Reminders interface {
+	// ScheduleReminder 添加活动某类提醒，同一活动同一类还未发送的提醒会被更新，已发送过的相同时间的提醒不会重复添加
+	ScheduleReminder(kind string, remindAt time.Time, note string, e *utils.Event) error
+	// DueReminders 返回到了提醒时间、还未发送的提醒
+	DueReminders(now time.Time) ([]*Reminder, error)
+	SaveReminder(r *Reminder) error
+	// CancelReminders 取消活动这些类型还未发送的提醒
+	CancelReminders(key string, kinds ...string) error
+}
+
+type Reminder struct {
+	ID       uint   `gorm:"primarykey"`
+	EventKey string `gorm:"index"`
+	Kind     string
+	// Note 提醒的内容，如 门票将于 03-01 12:00 开售
+	Note      string
+	Payload   string
+	RemindAt  time.Time `gorm:"index"`
+	Status    string    `gorm:"index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (*Reminder) tableName() string {
+	return "reminders"
+}
+
+// Event 还原添加提醒时的活动信息
+func (r *Reminder) Event() (*utils.Event, error) {
+	var e utils.Event
+	if err := json.Unmarshal([]byte(r.Payload), &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *sqliteHandler) ScheduleReminder(kind string, remindAt time.Time, note string, e *utils.Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	r := &Reminder{}
+	if err := s.db.Table(r.tableName()).
+		Where("event_key = ? AND kind = ?", e.Key(), kind).
+		Order("id desc").Limit(1).Find(r).Error; err != nil {
+		return err
+	}
+	if r.ID != 0 && r.Status != ReminderPending {
+		// 已经发送过的提醒，提醒时间没有变化时不再添加
+		if r.RemindAt.Equal(remindAt) {
+			return nil
+		}
+		r = &Reminder{}
+	}
+	r.EventKey = e.Key()
+	r.Kind = kind
+	r.Note = note
+	r.Payload = string(payload)
+	r.RemindAt = remindAt
+	r.Status = ReminderPending
+	return s.db.Table(r.tableName()).Save(r).Error
+}
+
+func (s *sqliteHandler) DueReminders(now time.Time) ([]*Reminder, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	reminders := make([]*Reminder, 0)
+	if err := s.db.Table((&Reminder{}).tableName()).
+		Where("status = ? AND remind_at <= ?", ReminderPending, now).
+		Order("remind_at").
+		Find(&reminders).Error; err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+func (s *sqliteHandler) SaveReminder(r *Reminder) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.db.Table(r.tableName()).Save(r).Error
+}
+
+func (s *sqliteHandler) CancelReminders(key string, kinds ...string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.db.Table((&Reminder{}).tableName()).
+		Where("event_key = ? AND kind IN ? AND status = ?", key, kinds, ReminderPending).
+		UpdateColumn("status", ReminderCanceled).Error
+}
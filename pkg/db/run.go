@@ -0,0 +1,75 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// Runs 保存每次运行的结果，目前只有 sqlite 实现了该接口
+type Runs interface {
+	SaveRun(r *Run) error
+	// RecentRuns 返回最近的 limit 次运行，source 为空时返回所有平台的
+	RecentRuns(source string, limit int) ([]*Run, error)
+}
+
+// Run 一次运行的结果
+type Run struct {
+	ID         uint   `gorm:"primarykey"`
+	Source     string `gorm:"index"`
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Found 获取到的新活动个数，Filtered 被过滤掉的个数，Notified 需要通知的个数
+	Found    int
+	Filtered int
+	Notified int
+	// Seen 活动列表中的活动总数，包括已推送过的活动，平台不支持时为0
+	Seen int
+	// Problems 抓取结果的异常，以换行分隔，如活动列表的第一页为空
+	Problems string
+	// Alerted 抓取结果异常的通知已经送达，异常持续时之后的运行沿用该值，不再重复通知
+	Alerted bool
+	// EventKeys 需要通知的活动在数据库中的键，以逗号分隔
+	EventKeys string
+	// Error 运行出错时的错误
+	Error string
+}
+
+func (*Run) tableName() string {
+	return "runs"
+}
+
+// Keys 需要通知的活动在数据库中的键
+func (r *Run) Keys() []string {
+	if r.EventKeys == "" {
+		return []string{}
+	}
+	return strings.Split(r.EventKeys, ",")
+}
+
+// ProblemList 抓取结果的异常
+func (r *Run) ProblemList() []string {
+	if r.Problems == "" {
+		return []string{}
+	}
+	return strings.Split(r.Problems, "\n")
+}
+
+func (s *sqliteHandler) SaveRun(r *Run) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.db.Table(r.tableName()).Save(r).Error
+}
+
+func (s *sqliteHandler) RecentRuns(source string, limit int) ([]*Run, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	runs := make([]*Run, 0, limit)
+	query := s.db.Table((&Run{}).tableName()).Order("id desc").Limit(limit)
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if err := query.Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
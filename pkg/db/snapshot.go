@@ -0,0 +1,110 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"show-live/utils"
+)
+
+// Snapshots 保存活动每次发生变化时的信息，用于发现票价、时间、场地、艺人的变化。目前只有 sqlite 实现了该接口
+type Snapshots interface {
+	// LatestSnapshot 返回活动最近一次的快照，没有时返回 nil
+	LatestSnapshot(key string) (*Snapshot, error)
+	SaveSnapshot(e *utils.Event) error
+	// RecentEvents 返回最近发现的 limit 个活动，按发现的时间倒序
+	RecentEvents(limit int) ([]*Discovered, error)
+}
+
+// Discovered 活动的最新信息，以及第一次发现和最近一次变化的时间
+type Discovered struct {
+	Event        *utils.Event
+	DiscoveredAt time.Time
+	UpdatedAt    time.Time
+}
+
+type Snapshot struct {
+	ID        uint   `gorm:"primarykey"`
+	EventKey  string `gorm:"index"`
+	Source    string
+	Payload   string
+	CreatedAt time.Time
+}
+
+func (*Snapshot) tableName() string {
+	return "snapshots"
+}
+
+// Event 还原快照中的活动信息
+func (s *Snapshot) Event() (*utils.Event, error) {
+	var e utils.Event
+	if err := json.Unmarshal([]byte(s.Payload), &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *sqliteHandler) LatestSnapshot(key string) (*Snapshot, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.latestSnapshot(key)
+}
+
+func (s *sqliteHandler) latestSnapshot(key string) (*Snapshot, error) {
+	snapshots := make([]*Snapshot, 0, 1)
+	if err := s.db.Table((&Snapshot{}).tableName()).
+		Where("event_key = ?", key).
+		Order("id desc").Limit(1).
+		Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+	return snapshots[0], nil
+}
+
+func (s *sqliteHandler) SaveSnapshot(e *utils.Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	snapshot := &Snapshot{
+		EventKey: e.Key(),
+		Source:   e.Source,
+		Payload:  string(payload),
+	}
+	return s.db.Table(snapshot.tableName()).Create(snapshot).Error
+}
+
+func (s *sqliteHandler) RecentEvents(limit int) ([]*Discovered, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	table := (&Snapshot{}).tableName()
+	first := make([]*Snapshot, 0, limit)
+	if err := s.db.Table(table).
+		Where("id IN (?)", s.db.Table(table).Select("MIN(id)").Group("event_key")).
+		Order("id desc").Limit(limit).
+		Find(&first).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*Discovered, 0, len(first))
+	for _, f := range first {
+		latest, err := s.latestSnapshot(f.EventKey)
+		if err != nil {
+			return nil, err
+		}
+		e, err := latest.Event()
+		if err != nil {
+			continue
+		}
+		result = append(result, &Discovered{
+			Event:        e,
+			DiscoveredAt: f.CreatedAt,
+			UpdatedAt:    latest.CreatedAt,
+		})
+	}
+	return result, nil
+}
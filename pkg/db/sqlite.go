@@ -3,6 +3,7 @@ package db
 import (
 	"os"
 	"sync"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -16,7 +17,8 @@ type sqliteConfig struct {
 }
 
 type sqliteHandler struct {
-	db   *gorm.DB
+	db *gorm.DB
+	// lock 所有读写都需要持有该锁，保证多个平台并发请求、HTTP 服务同时访问时数据库是安全的
 	lock sync.Mutex
 }
 
@@ -24,6 +26,8 @@ type event struct {
 	Event  string
 	Name   string
 	Status string
+	// FailedAt 活动第一次404或请求出错的时间，状态不是404或请求出错时为空
+	FailedAt *time.Time
 }
 
 func (*event) tableName() string {
@@ -44,26 +48,66 @@ func InitSqlite(dbFile string) (*sqliteHandler, error) {
 	}
 	p := &event{}
 	db.Table(p.tableName()).AutoMigrate(&p)
+	// 旧版本没有记录出错的时间，从现在开始计算
+	if err := db.Table(p.tableName()).Where("failed_at IS NULL AND status IN ?", failedStatuses).
+		UpdateColumn("failed_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+	o := &OutboxItem{}
+	if err := db.Table(o.tableName()).AutoMigrate(o); err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{}
+	if err := db.Table(snapshot.tableName()).AutoMigrate(snapshot); err != nil {
+		return nil, err
+	}
+	reminder := &Reminder{}
+	if err := db.Table(reminder.tableName()).AutoMigrate(reminder); err != nil {
+		return nil, err
+	}
+	mute := &Mute{}
+	if err := db.Table(mute.tableName()).AutoMigrate(mute); err != nil {
+		return nil, err
+	}
+	run := &Run{}
+	if err := db.Table(run.tableName()).AutoMigrate(run); err != nil {
+		return nil, err
+	}
 	return &sqliteHandler{
 		db: db,
 	}, nil
 }
 
 func (s *sqliteHandler) SetKey(key, name, value string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	r := &event{}
+	var failedAt *time.Time
+	if isFailed(value) {
+		now := time.Now()
+		failedAt = &now
+	}
 	results := s.db.Table(r.tableName()).Where("event = ?", key).First(r)
 	if results.Error != nil {
 		if results.Error == gorm.ErrRecordNotFound {
 			results := s.db.Table(r.tableName()).Create(&event{
-				Event:  key,
-				Name:   name,
-				Status: value,
+				Event:    key,
+				Name:     name,
+				Status:   value,
+				FailedAt: failedAt,
 			})
 			if results.Error != nil {
 				return results.Error
 			}
 		}
 	} else {
+		// 一直404或请求出错时保留第一次出错的时间
+		if failedAt == nil || r.FailedAt == nil {
+			if err := s.db.Table(r.tableName()).Where("event = ?", key).
+				UpdateColumn("failed_at", failedAt).Error; err != nil {
+				return err
+			}
+		}
 		if err := s.db.Model(&r).Where("event = ?", key).
 			UpdateColumn("status", value).Error; err != nil {
 			return err
@@ -77,6 +121,8 @@ func (s *sqliteHandler) SetKey(key, name, value string) error {
 }
 
 func (s *sqliteHandler) Exists(key string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	var exists bool
 	if err := s.db.Model(&event{}).
 		Select("count(*) > 0").
@@ -92,6 +138,8 @@ func (s *sqliteHandler) Exists(key string) (bool, error) {
 }
 
 func (s *sqliteHandler) GetValue(key string) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	var status string
 	if err := s.db.Model(&event{}).Where("event = ?", key).
 		Select("status").Scan(&status).Error; err != nil {
@@ -100,7 +148,20 @@ func (s *sqliteHandler) GetValue(key string) (string, error) {
 	return status, nil
 }
 
+func (s *sqliteHandler) FailedSince(status string, since time.Time) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var events []string
+	if err := s.db.Model(&event{}).Where("status = ? AND failed_at >= ?", status, since).
+		Select("event").Scan(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
 func (s *sqliteHandler) GetEventByValue(value string) ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	var events []string
 	if err := s.db.Model(&event{}).Where("status = ?", value).
 		Select("event").Scan(&events).Error; err != nil {
@@ -1,6 +1,8 @@
 package email
 
 import (
+	"io"
+
 	"gopkg.in/gomail.v2"
 
 	"show-live/config"
@@ -16,15 +18,63 @@ func NewEmailSender(conf config.EmailConfig) *EmailSender {
 	}
 }
 
-func (e *EmailSender) Send(title, content string) error {
+// Attachment 邮件附件
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Mail 一封 HTML 邮件
+type Mail struct {
+	Title   string
+	Content string
+	// Embeds 内嵌到邮件中的图片，在内容中通过 cid:文件名 引用
+	Embeds      []string
+	Attachments []Attachment
+	// Urgent 将邮件标记为重要
+	Urgent bool
+}
+
+// Send 发送 HTML 邮件，embeds 中的图片会内嵌到邮件中，在内容中通过 cid:文件名 引用
+func (e *EmailSender) Send(title, content string, embeds ...string) error {
+	return e.SendMail(Mail{Title: title, Content: content, Embeds: embeds})
+}
+
+// SendUrgent 与 Send 相同，但会将邮件标记为重要
+func (e *EmailSender) SendUrgent(title, content string, embeds ...string) error {
+	return e.SendMail(Mail{Title: title, Content: content, Embeds: embeds, Urgent: true})
+}
+
+// SendMail 发送邮件，可以带附件
+func (e *EmailSender) SendMail(mail Mail) error {
 	m := gomail.NewMessage()
 	m.SetHeader("From", e.Conf.From)
 	m.SetHeader("To", e.Conf.To)
 
-	m.SetHeader("Subject", title)
+	m.SetHeader("Subject", mail.Title)
+	if mail.Urgent {
+		m.SetHeader("X-Priority", "1")
+		m.SetHeader("Importance", "High")
+	}
 
-	m.SetBody("text/html", content)
+	m.SetBody("text/html", mail.Content)
+	for _, f := range mail.Embeds {
+		m.Embed(f)
+	}
+	for _, a := range mail.Attachments {
+		data := a.Data
+		m.Attach(a.Name,
+			gomail.SetHeader(map[string][]string{"Content-Type": {a.ContentType}}),
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			}))
+	}
+	return e.send(m)
+}
 
+func (e *EmailSender) send(m *gomail.Message) error {
 	d := gomail.NewPlainDialer(e.Conf.Server, e.Conf.Port, e.Conf.From, e.Conf.Password)
 	err := d.DialAndSend(m)
 	return err
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"sync"
+	"time"
+
+	"show-live/pkg/log"
+)
+
+// breaker 按域名的熔断器，连续 threshold 次请求失败后熔断 cooldown，
+// 之后只放行一个请求试探，成功则恢复，失败则继续熔断
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	lock      sync.Mutex
+	hosts     map[string]*circuit
+}
+
+type circuit struct {
+	failures int
+	openedAt time.Time
+	// probing 熔断结束后已经放行了一个试探的请求，还没有结果
+	probing bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		hosts:     make(map[string]*circuit),
+	}
+}
+
+// allow 返回是否可以请求 host，返回 true 时请求结束后需要调用 done
+func (b *breaker) allow(host string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c, ok := b.hosts[host]
+	if !ok || c.failures < b.threshold {
+		return true
+	}
+	if c.probing || time.Since(c.openedAt) < b.cooldown {
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+func (b *breaker) done(host string, ok bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c, exists := b.hosts[host]
+	if !exists {
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+	if ok {
+		if c.failures >= b.threshold {
+			log.Logger.Infof("%s 已恢复，结束熔断", host)
+		}
+		c.failures = 0
+		c.probing = false
+		return
+	}
+	c.failures++
+	if c.failures >= b.threshold {
+		if c.probing || c.failures == b.threshold {
+			log.Logger.Errorf("%s 连续 %d 次请求失败，%v 内不再请求", host, c.failures, b.cooldown)
+		}
+		c.openedAt = time.Now()
+		c.probing = false
+	}
+}
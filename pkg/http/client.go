@@ -0,0 +1,262 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"show-live/config"
+	"show-live/pkg/log"
+)
+
+const (
+	defaultTimeout          = 30 * time.Second
+	defaultRate             = 5
+	defaultRetries          = 3
+	defaultBaseBackoff      = time.Second
+	defaultMaxBackoff       = 30 * time.Second
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 5 * time.Minute
+)
+
+var (
+	jitter     = rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitterLock sync.Mutex
+)
+
+// ErrCircuitOpen 域名处于熔断中，请求没有发出
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Client 所有平台共用的 HTTP 客户端，请求带超时，同一个域名按令牌桶限速，
+// 请求出错、返回 5xx 或 429 时按指数退避加随机抖动重试，同一个域名连续失败多次后熔断。
+// 配置了代理和浏览器请求头时每次请求轮流使用
+type Client struct {
+	client      *http.Client
+	rate        *rateLimiter
+	breaker     *breaker
+	proxies     *proxyPool
+	profiles    *profiles
+	retries     int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+// Default 各平台请求时使用的客户端，启动时通过 Configure 按配置替换
+var Default = NewClient(config.HTTP{})
+
+// Configure 按配置创建 Default
+func Configure(conf config.HTTP) error {
+	c, err := NewClientWithConfig(conf)
+	if err != nil {
+		return err
+	}
+	Default = c
+	return nil
+}
+
+// NewClient 创建客户端，配置错误时使用默认值
+func NewClient(conf config.HTTP) *Client {
+	c, err := NewClientWithConfig(conf)
+	if err != nil {
+		log.Logger.Errorf("HTTP 客户端配置错误 %v，使用默认配置", err)
+		c, _ = NewClientWithConfig(config.HTTP{})
+	}
+	return c
+}
+
+// NewClientWithConfig 创建客户端，未配置的项使用默认值
+func NewClientWithConfig(conf config.HTTP) (*Client, error) {
+	timeout, err := durationOr(conf.Timeout, defaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("timeout 格式错误 %v", err)
+	}
+	baseBackoff, err := durationOr(conf.BaseBackoff, defaultBaseBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("base_backoff 格式错误 %v", err)
+	}
+	maxBackoff, err := durationOr(conf.MaxBackoff, defaultMaxBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("max_backoff 格式错误 %v", err)
+	}
+	cooldown, err := durationOr(conf.BreakerCooldown, defaultBreakerCooldown)
+	if err != nil {
+		return nil, fmt.Errorf("breaker_cooldown 格式错误 %v", err)
+	}
+	rate := conf.Rate
+	if rate <= 0 {
+		rate = defaultRate
+	}
+	burst := conf.Burst
+	if burst <= 0 {
+		burst = int(rate)
+		if float64(burst) < rate {
+			burst++
+		}
+	}
+	retries := conf.Retries
+	if retries <= 0 {
+		retries = defaultRetries
+	}
+	threshold := conf.BreakerThreshold
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+	proxyCooldown, err := durationOr(conf.ProxyCooldown, defaultProxyCooldown)
+	if err != nil {
+		return nil, fmt.Errorf("proxy_cooldown 格式错误 %v", err)
+	}
+	maxFailures := conf.ProxyMaxFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultProxyMaxFailures
+	}
+	proxies, err := newProxyPool(conf.Proxies, maxFailures, proxyCooldown)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxies.proxyFunc
+	var rt http.RoundTripper = transport
+	switch {
+	case conf.Record != "" && conf.Replay != "":
+		return nil, errors.New("record 和 replay 不能同时配置")
+	case conf.Record != "":
+		rt = &recorder{next: transport, files: newFixtureFiles(conf.Record)}
+	case conf.Replay != "":
+		rt = &replayer{files: newFixtureFiles(conf.Replay)}
+	}
+	return &Client{
+		client:      &http.Client{Timeout: timeout, Transport: rt},
+		rate:        newRateLimiter(rate, burst),
+		breaker:     newBreaker(threshold, cooldown),
+		proxies:     proxies,
+		profiles:    &profiles{list: conf.Profiles},
+		retries:     retries,
+		baseBackoff: baseBackoff,
+		maxBackoff:  maxBackoff,
+	}, nil
+}
+
+func durationOr(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Get 使用 Default 发起 GET 请求
+func Get(url string) (*http.Response, error) {
+	return Default.Get(url)
+}
+
+// Do 使用 Default 发起请求
+func Do(req *http.Request) (*http.Response, error) {
+	return Default.Do(req)
+}
+
+func (c *Client) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do 发起请求，失败时重试，重试用完后返回最后一次的响应或错误，调用方需要关闭返回的响应的 Body。
+// 有请求体的请求需要能通过 GetBody 重新获取请求体才会重试
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if !c.breaker.allow(host) {
+		return nil, fmt.Errorf("%s %w", host, ErrCircuitOpen)
+	}
+	resp, err := c.retry(req)
+	c.breaker.done(host, !retryable(resp, err))
+	return resp, err
+}
+
+func (c *Client) retry(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		if err := c.rate.wait(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+		resp, err := c.send(req)
+		if !(retryable(resp, err) || c.proxies != nil && proxyFailed(resp, err)) ||
+			attempt >= c.retries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		wait := c.backoff(attempt, resp)
+		if err != nil {
+			log.Logger.Errorf("请求 %s 出错 %v，%v 后第 %d 次重试", req.URL, err, wait, attempt+1)
+		} else {
+			log.Logger.Errorf("请求 %s 返回 %d，%v 后第 %d 次重试", req.URL, resp.StatusCode, wait, attempt+1)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// send 轮流选择代理和浏览器请求头发起一次请求，请求头设置在副本上，不修改 req
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	pr := c.proxies.pick()
+	r := req.Clone(context.WithValue(req.Context(), proxyKey{}, pr))
+	apply(r.Header, c.profiles.pick())
+	resp, err := c.client.Do(r)
+	if pr == nil {
+		return resp, err
+	}
+	c.proxies.done(pr, !proxyFailed(resp, err))
+	if err != nil {
+		log.Logger.Errorf("通过代理 %s 请求 %s 出错 %v", pr, req.URL, err)
+	} else {
+		log.Logger.Infof("通过代理 %s 请求 %s 返回 %d", pr, req.URL, resp.StatusCode)
+	}
+	return resp, err
+}
+
+// retryable 请求出错、返回 5xx 或 429 时需要重试
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// backoff 第 attempt 次失败后的等待时间，baseBackoff 翻倍 attempt 次后，在它的一半到全部之间随机取值，
+// 避免多个请求同时重试，响应带有 Retry-After 时至少等待该时间，都不超过 maxBackoff
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	wait := c.baseBackoff << attempt
+	if wait <= 0 || wait > c.maxBackoff {
+		wait = c.maxBackoff
+	}
+	jitterLock.Lock()
+	wait = wait/2 + time.Duration(jitter.Int63n(int64(wait/2)+1))
+	jitterLock.Unlock()
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			if after := time.Duration(seconds) * time.Second; after > wait {
+				wait = after
+			}
+		}
+	}
+	if wait > c.maxBackoff {
+		wait = c.maxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"show-live/pkg/log"
+)
+
+// fixture 录制的一次响应，同一个请求地址多次请求时按请求的次数分别保存，回放时按相同的顺序返回
+type fixture struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+// fixtureFiles 按请求方法和地址生成录制文件的文件名，请求体中可能带有当前时间，不参与文件名
+type fixtureFiles struct {
+	dir   string
+	lock  sync.Mutex
+	count map[string]int
+}
+
+func newFixtureFiles(dir string) *fixtureFiles {
+	return &fixtureFiles{
+		dir:   dir,
+		count: make(map[string]int),
+	}
+}
+
+// next 返回该请求下一次响应的文件
+func (f *fixtureFiles) next(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+	key := strings.ReplaceAll(req.URL.Host, ":", "_") + "-" + hex.EncodeToString(sum[:6])
+	f.lock.Lock()
+	f.count[key]++
+	n := f.count[key]
+	f.lock.Unlock()
+	return filepath.Join(f.dir, fmt.Sprintf("%s-%d.json", key, n))
+}
+
+// recorder 发出请求并保存响应
+type recorder struct {
+	next  http.RoundTripper
+	files *fixtureFiles
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	file := r.files.next(req)
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	v, _ := json.MarshalIndent(fixture{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        string(body),
+	}, "", "  ")
+	if err := os.MkdirAll(r.files.dir, os.ModePerm); err != nil {
+		log.Logger.Errorf("创建录制目录 %s 出错 %v", r.files.dir, err)
+		return resp, nil
+	}
+	if err := os.WriteFile(file, v, 0644); err != nil {
+		log.Logger.Errorf("保存 %s 的响应到 %s 出错 %v", req.URL, file, err)
+	}
+	return resp, nil
+}
+
+// replayer 不发出请求，返回录制的响应
+type replayer struct {
+	files *fixtureFiles
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	file := r.files.next(req)
+	v, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("没有录制 %s %s 的响应 %v", req.Method, req.URL, err)
+	}
+	var f fixture
+	if err := json.Unmarshal(v, &f); err != nil {
+		return nil, fmt.Errorf("录制的响应 %s 格式错误 %v", file, err)
+	}
+	header := make(http.Header)
+	if f.ContentType != "" {
+		header.Set("Content-Type", f.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// HostLimiter 限制同一个域名同时进行的请求数
+type HostLimiter struct {
+	max  int
+	lock sync.Mutex
+	sems map[string]chan struct{}
+}
+
+// NewHostLimiter max 小于等于0时不限制
+func NewHostLimiter(max int) *HostLimiter {
+	return &HostLimiter{
+		max:  max,
+		sems: make(map[string]chan struct{}),
+	}
+}
+
+// Acquire 等待直到可以请求 rawURL 所在的域名，请求结束后需要调用返回的函数
+func (l *HostLimiter) Acquire(rawURL string) func() {
+	if l == nil || l.max <= 0 {
+		return func() {}
+	}
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	l.lock.Lock()
+	sem, ok := l.sems[host]
+	if !ok {
+		sem = make(chan struct{}, l.max)
+		l.sems[host] = sem
+	}
+	l.lock.Unlock()
+	sem <- struct{}{}
+	return func() { <-sem }
+}
+
+// rateLimiter 按域名的令牌桶，每个域名每秒补充 rate 个令牌，最多存 burst 个
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	lock    sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// wait 取一个令牌，没有令牌时等待补充，ctx 结束时返回错误
+func (l *rateLimiter) wait(ctx context.Context, host string) error {
+	d := l.reserve(host)
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve 先扣除一个令牌，令牌不足时返回需要等待的时间，这样同时等待的请求按顺序依次发出
+func (l *rateLimiter) reserve(host string) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[host] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+
+	"show-live/config"
+)
+
+// profiles 轮流使用的浏览器请求头
+type profiles struct {
+	lock sync.Mutex
+	list []config.HeaderProfile
+	next int
+}
+
+// pick 返回下一组请求头，没有配置时返回 nil
+func (p *profiles) pick() *config.HeaderProfile {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.list) == 0 {
+		return nil
+	}
+	profile := &p.list[p.next]
+	p.next = (p.next + 1) % len(p.list)
+	return profile
+}
+
+// apply 设置请求头，已经设置的请求头不会被覆盖
+func apply(h http.Header, profile *config.HeaderProfile) {
+	if profile == nil {
+		return
+	}
+	if profile.UserAgent != "" && h.Get("User-Agent") == "" {
+		h.Set("User-Agent", profile.UserAgent)
+	}
+	for k, v := range profile.Headers {
+		if h.Get(k) == "" {
+			h.Set(k, v)
+		}
+	}
+}
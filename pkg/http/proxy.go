@@ -0,0 +1,123 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"show-live/pkg/log"
+)
+
+const (
+	defaultProxyMaxFailures = 3
+	defaultProxyCooldown    = 10 * time.Minute
+)
+
+type proxyKey struct{}
+
+type proxy struct {
+	url      *url.URL
+	failures int
+	downAt   time.Time
+}
+
+func (p *proxy) String() string {
+	return p.url.Redacted()
+}
+
+// proxyPool 轮流使用的代理，连续失败 maxFailures 次的代理在 cooldown 内不再使用
+type proxyPool struct {
+	maxFailures int
+	cooldown    time.Duration
+	lock        sync.Mutex
+	proxies     []*proxy
+	next        int
+}
+
+// newProxyPool 没有配置代理时返回 nil
+func newProxyPool(addrs []string, maxFailures int, cooldown time.Duration) (*proxyPool, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	p := &proxyPool{
+		maxFailures: maxFailures,
+		cooldown:    cooldown,
+	}
+	for _, addr := range addrs {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("代理地址 %s 格式错误 %v", addr, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("不支持代理地址 %s，只支持 http、https、socks5", u.Redacted())
+		}
+		p.proxies = append(p.proxies, &proxy{url: u})
+	}
+	return p, nil
+}
+
+// pick 轮流返回下一个可用的代理，都不可用时返回 nil
+func (p *proxyPool) pick() *proxy {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i := range p.proxies {
+		pr := p.proxies[(p.next+i)%len(p.proxies)]
+		if pr.failures < p.maxFailures || time.Since(pr.downAt) >= p.cooldown {
+			p.next = (p.next + i + 1) % len(p.proxies)
+			return pr
+		}
+	}
+	log.Logger.Errorf("所有代理都不可用，直接请求")
+	return nil
+}
+
+// done 记录通过代理请求的结果，不可用的代理过了 cooldown 后再次失败会继续标记为不可用
+func (p *proxyPool) done(pr *proxy, ok bool) {
+	if p == nil || pr == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if ok {
+		if pr.failures >= p.maxFailures {
+			log.Logger.Infof("代理 %s 已恢复", pr)
+		}
+		pr.failures = 0
+		return
+	}
+	pr.failures++
+	if pr.failures >= p.maxFailures {
+		pr.downAt = time.Now()
+		log.Logger.Errorf("代理 %s 连续 %d 次失败，%v 内标记为不可用", pr, pr.failures, p.cooldown)
+	}
+}
+
+// proxyFunc 使用请求中选好的代理，没有配置代理时与默认的客户端一样使用环境变量中的代理
+func (p *proxyPool) proxyFunc(req *http.Request) (*url.URL, error) {
+	if pr, ok := req.Context().Value(proxyKey{}).(*proxy); ok && pr != nil {
+		return pr.url, nil
+	}
+	if p == nil {
+		return http.ProxyFromEnvironment(req)
+	}
+	return nil, nil
+}
+
+// proxyFailed 通过代理请求出错，或被网站拒绝、限流时认为代理失败
+func proxyFailed(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusProxyAuthRequired, http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
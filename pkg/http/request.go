@@ -22,11 +22,11 @@ func Request(url, method string, postBody interface{}, out interface{}) error {
 	}
 	req.Header.Add("Cache-Control", "no-cache")
 	req.Header.Add("Content-Type", "application/json")
-	client := http.Client{}
-	resp, err := client.Do(req)
+	resp, err := Default.Do(req)
 	if err != nil {
 		return fmt.Errorf("do request error %v", err)
 	}
+	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Logger.Errorf("读取响应body出错 %v", err)
@@ -0,0 +1,118 @@
+package ical
+
+import (
+	"strings"
+	"time"
+
+	"show-live/utils"
+)
+
+const (
+	// ContentType .ics 文件的类型
+	ContentType = "text/calendar; charset=utf-8; method=PUBLISH"
+	// defaultDuration 没有结束时间的演出默认持续的时间
+	defaultDuration = 2 * time.Hour
+	// maxLineLength 每行最多的字节数，超过时需要折行
+	maxLineLength = 75
+)
+
+// Calendar 生成包含活动的日历，没有开始时间的活动会被忽略。
+// 活动的 UID 由来源平台和平台内的活动ID组成，同一个活动重复导入或订阅时会更新而不是新增
+func Calendar(name string, events []*utils.Event) []byte {
+	now := time.Now()
+	var b strings.Builder
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:-//show-live//show-live//CN")
+	writeLine(&b, "CALSCALE:GREGORIAN")
+	writeLine(&b, "METHOD:PUBLISH")
+	writeLine(&b, "X-WR-CALNAME:"+escape(name))
+	writeLine(&b, "X-WR-TIMEZONE:Asia/Shanghai")
+	for _, e := range events {
+		if e.Start.IsZero() {
+			continue
+		}
+		writeLine(&b, "BEGIN:VEVENT")
+		writeLine(&b, "UID:"+UID(e))
+		writeLine(&b, "DTSTAMP:"+utc(now))
+		start, end := e.Start.In(utils.Location), e.End.In(utils.Location)
+		if allDay(start) && (e.End.IsZero() || allDay(end)) {
+			if e.End.IsZero() {
+				end = start.AddDate(0, 0, 1)
+			}
+			writeLine(&b, "DTSTART;VALUE=DATE:"+start.Format("20060102"))
+			writeLine(&b, "DTEND;VALUE=DATE:"+end.Format("20060102"))
+		} else {
+			if e.End.IsZero() {
+				end = start.Add(defaultDuration)
+			}
+			writeLine(&b, "DTSTART:"+utc(start))
+			writeLine(&b, "DTEND:"+utc(end))
+		}
+		writeLine(&b, "SUMMARY:"+escape(e.Name))
+		if e.Site != "" {
+			writeLine(&b, "LOCATION:"+escape(strings.TrimSpace(e.City+" "+e.Site)))
+		}
+		if desc := description(e); desc != "" {
+			writeLine(&b, "DESCRIPTION:"+escape(desc))
+		}
+		if e.WebURL != "" {
+			writeLine(&b, "URL:"+e.WebURL)
+		}
+		writeLine(&b, "END:VEVENT")
+	}
+	writeLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// UID 活动在日历中的唯一ID
+func UID(e *utils.Event) string {
+	return e.Key() + "@show-live"
+}
+
+func description(e *utils.Event) string {
+	lines := make([]string, 0, 4)
+	for _, f := range []struct {
+		name, value string
+	}{
+		{"演出时间", e.Time},
+		{"艺人", e.Artist},
+		{"票价", e.Price},
+		{"详情", e.WebURL},
+	} {
+		if v := strings.TrimSpace(f.value); v != "" {
+			lines = append(lines, f.name+"："+v)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func allDay(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
+}
+
+func utc(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escape 转义文本中的特殊字符
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeLine 写入一行内容，超过 75 字节时折行，折行不会截断多字节的字符
+func writeLine(b *strings.Builder, line string) {
+	n := 0
+	for _, r := range line {
+		size := len(string(r))
+		if n+size > maxLineLength {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	b.WriteString("\r\n")
+}
@@ -17,6 +17,7 @@ var Logger *logrus.Logger
 var suffix string
 var dir string
 var currentLogFile string
+var currentFile *os.File
 
 func InitLogger(logSuffix string, logDir string) {
 	Logger = &logrus.Logger{
@@ -59,6 +60,15 @@ func setLogoutput() {
 		writers := []io.Writer{file}
 		fileAndStdoutWriter := io.MultiWriter(writers...)
 		Logger.SetOutput(fileAndStdoutWriter)
+		if currentFile != nil {
+			currentFile.Close()
+		}
 		currentLogFile = logFileNow
+		currentFile = file
 	}
 }
+
+// Rotate 日期变化时切换到新的日志文件，供常驻运行的进程在每次任务开始前调用
+func Rotate() {
+	setLogoutput()
+}
@@ -0,0 +1,43 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// client 所有通知渠道共用的 HTTP 客户端，只设置超时。不使用请求平台时的代理、浏览器请求头、限速、熔断和录制回放，
+// 也不重试，失败时由 pipeline 或通知渠道自己决定是否重试，避免同一条消息被重复发送
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// postJSON 以 JSON 格式 POST body，将响应解析到 out
+func postJSON(url string, body interface{}, out interface{}) error {
+	v, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request body error %v", err)
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(v))
+	if err != nil {
+		return fmt.Errorf("new request error %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request error %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response body error %v", err)
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("返回 %d", resp.StatusCode)
+		}
+		return fmt.Errorf("unmarshal response body error %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package notifier
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const dingtalkMessageLimit = 18000
+
+type dingtalk struct {
+	webhook, secret string
+	mention         Mention
+}
+
+// NewDingTalk 钉钉群机器人，secret 为安全设置中的加签密钥，没有开启加签时为空
+func NewDingTalk(webhook, secret string, mention Mention) Notifier {
+	return &dingtalk{
+		webhook: webhook,
+		secret:  secret,
+		mention: mention,
+	}
+}
+
+func (n *dingtalk) Name() string {
+	return typeDingTalk
+}
+
+func (n *dingtalk) Notify(msg *Message) error {
+	if msg.empty() {
+		return nil
+	}
+	texts := markdown(msg, dingtalkMessageLimit)
+	return msg.sendParts(n, len(texts), func(i int) error {
+		text := texts[i]
+		req := map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"title": msg.Title,
+				"text":  text,
+			},
+		}
+		if msg.Priority {
+			// 钉钉需要在内容中带上 @手机号 才会高亮显示
+			for _, u := range n.mention.Users {
+				text += " @" + u
+			}
+			req["markdown"].(map[string]string)["text"] = text
+			req["at"] = map[string]interface{}{
+				"atMobiles": n.mention.Users,
+				"isAtAll":   n.mention.All,
+			}
+		}
+		var resp robotResp
+		if err := postJSON(n.url(), req, &resp); err != nil {
+			return err
+		}
+		if resp.ErrCode != 0 {
+			return fmt.Errorf("钉钉机器人返回错误 %d %s", resp.ErrCode, resp.ErrMsg)
+		}
+		return nil
+	})
+}
+
+// url 开启加签时需要在地址后附加毫秒时间戳和签名
+func (n *dingtalk) url() string {
+	if n.secret == "" {
+		return n.webhook
+	}
+	timestamp := fmt.Sprintf("%d", time.Now().UnixNano()/int64(time.Millisecond))
+	s := sign(n.secret, timestamp+"\n"+n.secret)
+	sep := "?"
+	if strings.Contains(n.webhook, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%stimestamp=%s&sign=%s", n.webhook, sep, timestamp, url.QueryEscape(s))
+}
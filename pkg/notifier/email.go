@@ -0,0 +1,55 @@
+package notifier
+
+import (
+	"show-live/pkg/email"
+	"show-live/pkg/ical"
+)
+
+// calendarFile 邮件中日历附件的文件名
+const calendarFile = "shows.ics"
+
+type emailNotifier struct {
+	sender *email.EmailSender
+}
+
+func NewEmail(sender *email.EmailSender) Notifier {
+	return &emailNotifier{
+		sender: sender,
+	}
+}
+
+func (n *emailNotifier) Name() string {
+	return typeEmail
+}
+
+// Notify 发送邮件，有演出时间的活动会放在 .ics 附件中，可以一键添加到日历
+func (n *emailNotifier) Notify(msg *Message) error {
+	content := msg.HTML
+	if content == "" {
+		content = msg.Text
+	}
+	mail := email.Mail{
+		Title:   msg.Title,
+		Content: content,
+		Embeds:  msg.Images,
+		Urgent:  msg.Priority,
+	}
+	if hasStart(msg) {
+		mail.Attachments = append(mail.Attachments, email.Attachment{
+			Name:        calendarFile,
+			ContentType: ical.ContentType,
+			Data:        ical.Calendar(msg.Title, msg.Events),
+		})
+	}
+	return n.sender.SendMail(mail)
+}
+
+// hasStart 是否有活动解析出了演出时间
+func hasStart(msg *Message) bool {
+	for _, e := range msg.Events {
+		if !e.Start.IsZero() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+)
+
+const feishuMessageLimit = 20000
+
+type feishu struct {
+	webhook, secret string
+	mention         Mention
+}
+
+// NewFeishu 飞书/Lark 群机器人，secret 为安全设置中的签名校验密钥，没有开启时为空
+func NewFeishu(webhook, secret string, mention Mention) Notifier {
+	return &feishu{
+		webhook: webhook,
+		secret:  secret,
+		mention: mention,
+	}
+}
+
+func (n *feishu) Name() string {
+	return typeFeishu
+}
+
+type feishuResp struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (n *feishu) Notify(msg *Message) error {
+	if msg.empty() {
+		return nil
+	}
+	texts := markdown(msg, feishuMessageLimit)
+	return msg.sendParts(n, len(texts), func(i int) error {
+		text := texts[i]
+		if msg.Priority {
+			if n.mention.All {
+				text += "<at id=all></at>"
+			}
+			for _, u := range n.mention.Users {
+				text += fmt.Sprintf("<at id=%s></at>", u)
+			}
+		}
+		req := map[string]interface{}{
+			"msg_type": "interactive",
+			"card": map[string]interface{}{
+				"header": map[string]interface{}{
+					"title": map[string]string{
+						"tag":     "plain_text",
+						"content": msg.Title,
+					},
+				},
+				"elements": []map[string]string{
+					{
+						"tag":     "markdown",
+						"content": text,
+					},
+				},
+			},
+		}
+		if n.secret != "" {
+			timestamp := fmt.Sprintf("%d", time.Now().Unix())
+			req["timestamp"] = timestamp
+			// 飞书的签名以 timestamp + "\n" + secret 作为密钥，对空字符串签名
+			req["sign"] = sign(timestamp+"\n"+n.secret, "")
+		}
+		var resp feishuResp
+		if err := postJSON(n.webhook, req, &resp); err != nil {
+			return err
+		}
+		if resp.Code != 0 {
+			return fmt.Errorf("飞书机器人返回错误 %d %s", resp.Code, resp.Msg)
+		}
+		return nil
+	})
+}
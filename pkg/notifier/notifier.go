@@ -0,0 +1,236 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"show-live/config"
+	"show-live/pkg/email"
+	"show-live/utils"
+)
+
+const (
+	typeEmail    = "email"
+	typeTelegram = "telegram"
+	typeWeCom    = "wecom"
+	typeDingTalk = "dingtalk"
+	typeFeishu   = "feishu"
+	typeWebhook  = "webhook"
+)
+
+// 通知的类型
+const (
+	// KindNew 新上的活动
+	KindNew = "new"
+	// KindChange 已推送活动的票价、时间等信息变化
+	KindChange = "change"
+	// KindReminder 开售提醒和演出提醒
+	KindReminder = "reminder"
+	// KindAlert 获取活动出错、抓取结果异常等
+	KindAlert = "alert"
+)
+
+// Message 一次通知的内容，各通知渠道根据自身支持的格式选择使用哪部分内容
+type Message struct {
+	// Kind 通知的类型，如 KindNew、KindChange
+	Kind  string
+	Title string
+	// HTML 邮件使用的 HTML 内容
+	HTML string
+	// Text 纯文本内容，如出错信息、活动的变化，聊天机器人会显示在活动列表前
+	Text   string
+	Events []*utils.Event
+	// Images 需要内嵌到邮件中的图片文件
+	Images []string
+	// Priority 高优先级的通知，如关注的艺人有新演出，聊天机器人会 @ 配置的成员
+	Priority bool
+	// sent 各聊天机器人已经发送成功的分段数，重试同一条消息时从第一个没有发送成功的分段继续
+	lock sync.Mutex
+	sent map[Notifier]int
+	// acked 已经推送成功的地址，重试同一条消息时跳过
+	acked map[string]bool
+}
+
+// isAcked 同一条消息之前是否已经成功推送到 url
+func (m *Message) isAcked(url string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.acked[url]
+}
+
+func (m *Message) ack(url string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.acked == nil {
+		m.acked = make(map[string]bool)
+	}
+	m.acked[url] = true
+}
+
+// sendParts 依次发送 count 个分段，跳过同一条消息之前已经通过 n 发送成功的分段
+func (m *Message) sendParts(n Notifier, count int, send func(i int) error) error {
+	m.lock.Lock()
+	start := m.sent[n]
+	m.lock.Unlock()
+	for i := start; i < count; i++ {
+		if err := send(i); err != nil {
+			return err
+		}
+		m.lock.Lock()
+		if m.sent == nil {
+			m.sent = make(map[Notifier]int)
+		}
+		m.sent[n] = i + 1
+		m.lock.Unlock()
+	}
+	return nil
+}
+
+// Mention 高优先级通知时聊天机器人需要 @ 的成员，
+// Users 在 telegram 中为用户名，企业微信和钉钉中为手机号，飞书中为 open_id
+type Mention struct {
+	Users []string
+	All   bool
+}
+
+// empty 没有活动也没有文本内容，聊天机器人不发送这类消息，避免每次运行都打扰群聊
+func (m *Message) empty() bool {
+	return len(m.Events) == 0 && m.Text == ""
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	Name() string
+	Notify(msg *Message) error
+}
+
+// Retrier 失败时自己会重试的通知渠道，调用方不需要再重试
+type Retrier interface {
+	SelfRetry() bool
+}
+
+// New 根据配置创建通知渠道，邮件渠道没有单独配置时使用 defaultEmail
+func New(conf config.Notifier, defaultEmail config.EmailConfig) (Notifier, error) {
+	mention := Mention{Users: conf.Mentions, All: conf.MentionAll}
+	switch conf.Type {
+	case typeEmail:
+		c := defaultEmail
+		if conf.Email != nil {
+			c = *conf.Email
+		}
+		return NewEmail(email.NewEmailSender(c)), nil
+	case typeTelegram:
+		if conf.Token == "" || conf.ChatID == "" {
+			return nil, fmt.Errorf("telegram 需要配置 token 和 chat_id")
+		}
+		return NewTelegram(conf.Token, conf.ChatID, mention), nil
+	case typeWeCom:
+		if conf.Webhook == "" {
+			return nil, fmt.Errorf("企业微信机器人需要配置 webhook")
+		}
+		return NewWeCom(conf.Webhook, mention), nil
+	case typeDingTalk:
+		if conf.Webhook == "" {
+			return nil, fmt.Errorf("钉钉机器人需要配置 webhook")
+		}
+		return NewDingTalk(conf.Webhook, conf.Secret, mention), nil
+	case typeFeishu:
+		if conf.Webhook == "" {
+			return nil, fmt.Errorf("飞书机器人需要配置 webhook")
+		}
+		return NewFeishu(conf.Webhook, conf.Secret, mention), nil
+	case typeWebhook:
+		if len(conf.URLs) == 0 {
+			return nil, fmt.Errorf("webhook 需要配置 urls")
+		}
+		return NewWebhook(conf.URLs, conf.Secret, conf.Retries), nil
+	default:
+		return nil, fmt.Errorf("不支持的通知渠道 %s", conf.Type)
+	}
+}
+
+// NewAll 根据配置创建所有通知渠道，没有配置任何渠道时只使用邮件
+func NewAll(confs []config.Notifier, defaultEmail config.EmailConfig) ([]Notifier, error) {
+	if len(confs) == 0 {
+		return []Notifier{NewEmail(email.NewEmailSender(defaultEmail))}, nil
+	}
+	notifiers := make([]Notifier, 0, len(confs))
+	for i, conf := range confs {
+		n, err := New(conf, defaultEmail)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个通知渠道配置错误 %v", i+1, err)
+		}
+		notifiers = append(notifiers, n)
+	}
+	return notifiers, nil
+}
+
+// markdown 生成聊天机器人使用的 markdown 内容，每段不超过 limit 字节
+func markdown(msg *Message, limit int) []string {
+	if len(msg.Events) == 0 {
+		return chunk(fmt.Sprintf("**%s**\n\n", msg.Title), strings.Split(msg.Text, "\n"), limit)
+	}
+	lines := make([]string, 0, len(msg.Events))
+	for _, e := range msg.Events {
+		name := e.Name
+		if e.WebURL != "" {
+			name = fmt.Sprintf("[%s](%s)", e.Name, e.WebURL)
+		}
+		lines = append(lines, fmt.Sprintf("- 🌈 **%s**\n  演出时间：%s\n  艺人：%s\n  场地：%s\n  票价：%s",
+			name, e.Time, e.Artist, e.Site, e.Price))
+	}
+	header := fmt.Sprintf("**%s**\n\n", msg.Title)
+	if msg.Text != "" {
+		header += msg.Text + "\n\n"
+	}
+	return chunk(header, lines, limit)
+}
+
+// chunk 将多行内容拼接成不超过 limit 字节的多段，每段都以 header 开头，单行过长时按字符拆成多行
+func chunk(header string, lines []string, limit int) []string {
+	chunks := make([]string, 0)
+	var b strings.Builder
+	b.WriteString(header)
+	for _, line := range splitLong(lines, limit-len(header)-1) {
+		if b.Len() > len(header) && b.Len()+len(line)+1 > limit {
+			chunks = append(chunks, b.String())
+			b.Reset()
+			b.WriteString(header)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return append(chunks, b.String())
+}
+
+// splitLong 将超过 max 字节的行按字符拆成多行，不会拆开一个字符
+func splitLong(lines []string, max int) []string {
+	if max < utf8.UTFMax {
+		max = utf8.UTFMax
+	}
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		for len(line) > max {
+			i := max
+			for i > 0 && !utf8.RuneStart(line[i]) {
+				i--
+			}
+			result = append(result, line[:i])
+			line = line[i:]
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// sign 使用 HMAC-SHA256 签名并进行 base64 编码，钉钉和飞书的加签方式都基于它
+func sign(key, data string) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,88 @@
+package notifier
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+const telegramAPI = "https://api.telegram.org"
+
+// telegram 消息最长 4096 个字符，按字节计算留出余量
+const telegramMessageLimit = 4000
+
+type telegram struct {
+	token, chatID string
+	mention       Mention
+}
+
+// NewTelegram 通过 Telegram Bot API 发送消息，chatID 可以是群组ID或 @频道名
+func NewTelegram(token, chatID string, mention Mention) Notifier {
+	return &telegram{
+		token:   token,
+		chatID:  chatID,
+		mention: mention,
+	}
+}
+
+func (n *telegram) Name() string {
+	return typeTelegram
+}
+
+type telegramResp struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func (n *telegram) Notify(msg *Message) error {
+	if msg.empty() {
+		return nil
+	}
+	texts := n.texts(msg)
+	return msg.sendParts(n, len(texts), func(i int) error {
+		req := map[string]interface{}{
+			"chat_id":                  n.chatID,
+			"text":                     texts[i],
+			"parse_mode":               "HTML",
+			"disable_web_page_preview": true,
+		}
+		var resp telegramResp
+		if err := postJSON(fmt.Sprintf("%s/bot%s/sendMessage", telegramAPI, n.token), req, &resp); err != nil {
+			return err
+		}
+		if !resp.OK {
+			return fmt.Errorf("telegram 返回错误 %s", resp.Description)
+		}
+		return nil
+	})
+}
+
+// texts telegram 的 markdown 需要转义大量字符，这里使用 HTML 格式
+func (n *telegram) texts(msg *Message) []string {
+	header := fmt.Sprintf("<b>%s</b>\n\n", html.EscapeString(msg.Title))
+	if msg.Priority {
+		header = "🔔 " + header
+		for _, u := range n.mention.Users {
+			header += "@" + strings.TrimPrefix(u, "@") + " "
+		}
+		if len(n.mention.Users) != 0 {
+			header += "\n\n"
+		}
+	}
+	if len(msg.Events) == 0 {
+		return chunk(header, strings.Split(html.EscapeString(msg.Text), "\n"), telegramMessageLimit)
+	}
+	if msg.Text != "" {
+		header += html.EscapeString(msg.Text) + "\n\n"
+	}
+	lines := make([]string, 0, len(msg.Events))
+	for _, e := range msg.Events {
+		name := fmt.Sprintf("<b>%s</b>", html.EscapeString(e.Name))
+		if e.WebURL != "" {
+			name = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(e.WebURL), name)
+		}
+		lines = append(lines, fmt.Sprintf("🌈 %s\n演出时间：%s\n艺人：%s\n场地：%s\n票价：%s\n",
+			name, html.EscapeString(e.Time), html.EscapeString(e.Artist), html.EscapeString(e.Site), html.EscapeString(e.Price)))
+	}
+	return chunk(header, lines, telegramMessageLimit)
+}
@@ -0,0 +1,161 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"show-live/pkg/log"
+	"show-live/utils"
+)
+
+const (
+	// webhookPayloadVersion 推送内容的格式版本，字段有不兼容的变化时递增
+	webhookPayloadVersion = 1
+	// webhookSignatureHeader 推送内容的签名，格式为 sha256=<hex>，与 GitHub webhook 一致
+	webhookSignatureHeader = "X-Show-Live-Signature"
+	webhookVersionHeader   = "X-Show-Live-Version"
+	defaultWebhookRetries  = 3
+	webhookBaseBackoff     = time.Second
+)
+
+var (
+	jitter     = rand.New(rand.NewSource(time.Now().UnixNano()))
+	jitterLock sync.Mutex
+)
+
+type webhook struct {
+	urls    []string
+	secret  string
+	retries int
+}
+
+// NewWebhook 将活动以 JSON 格式 POST 到 urls，secret 不为空时对请求体签名，失败时按指数退避重试 retries 次
+func NewWebhook(urls []string, secret string, retries int) Notifier {
+	if retries <= 0 {
+		retries = defaultWebhookRetries
+	}
+	return &webhook{
+		urls:    urls,
+		secret:  secret,
+		retries: retries,
+	}
+}
+
+func (n *webhook) Name() string {
+	return typeWebhook
+}
+
+func (n *webhook) SelfRetry() bool {
+	return true
+}
+
+// WebhookPayload 推送到 webhook 的内容
+type WebhookPayload struct {
+	Version int `json:"version"`
+	// Kind 通知的类型：new 新活动、change 已推送活动的信息变化、reminder 提醒、alert 出错或抓取异常
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	Text  string `json:"text,omitempty"`
+	// Priority 高优先级的通知，如关注的艺人有新演出
+	Priority bool           `json:"priority,omitempty"`
+	SentAt   time.Time      `json:"sent_at"`
+	Events   []*utils.Event `json:"events"`
+}
+
+func (n *webhook) Notify(msg *Message) error {
+	if msg.empty() {
+		return nil
+	}
+	events := msg.Events
+	if events == nil {
+		events = []*utils.Event{}
+	}
+	body, err := json.Marshal(WebhookPayload{
+		Version:  webhookPayloadVersion,
+		Kind:     msg.Kind,
+		Title:    msg.Title,
+		Text:     msg.Text,
+		Priority: msg.Priority,
+		SentAt:   time.Now(),
+		Events:   events,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化 webhook 内容出错 %v", err)
+	}
+	// 同一条消息再次推送时只推送到之前失败的地址，所有地址都成功后才算成功
+	var errToReturn error
+	for _, url := range n.urls {
+		if msg.isAcked(url) {
+			continue
+		}
+		if err := n.post(url, body); err != nil {
+			errToReturn = err
+			continue
+		}
+		msg.ack(url)
+	}
+	return errToReturn
+}
+
+// post 推送到单个地址，网络错误、5xx 和 429 会重试，第一次失败后最多重试 n.retries 次，其他 4xx 直接返回
+func (n *webhook) post(url string, body []byte) error {
+	var err error
+	for i := 0; i <= n.retries; i++ {
+		if i > 0 {
+			time.Sleep(backoff(i))
+		}
+		var retry bool
+		retry, err = n.postOnce(url, body)
+		if err == nil {
+			return nil
+		}
+		log.Logger.Errorf("推送到 webhook %s 失败 %v, 第 %d 次失败", url, err, i+1)
+		if !retry {
+			break
+		}
+	}
+	return err
+}
+
+// backoff 第 attempt 次重试前的等待时间，webhookBaseBackoff 翻倍后在它的一半到全部之间随机取值，
+// 避免多个地址同时重试
+func backoff(attempt int) time.Duration {
+	wait := webhookBaseBackoff << (attempt - 1)
+	jitterLock.Lock()
+	defer jitterLock.Unlock()
+	return wait/2 + time.Duration(jitter.Int63n(int64(wait/2)+1))
+}
+
+func (n *webhook) postOnce(url string, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("new request error %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookVersionHeader, fmt.Sprintf("%d", webhookPayloadVersion))
+	if n.secret != "" {
+		h := hmac.New(sha256.New, []byte(n.secret))
+		h.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(h.Sum(nil)))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook %s 返回 %d", url, resp.StatusCode)
+}
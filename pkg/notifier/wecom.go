@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"fmt"
+)
+
+// 企业微信机器人 markdown 内容最长 4096 字节
+const wecomMessageLimit = 4000
+
+type wecom struct {
+	webhook string
+	mention Mention
+}
+
+// NewWeCom 企业微信群机器人，webhook 为添加机器人后得到的完整地址
+func NewWeCom(webhook string, mention Mention) Notifier {
+	return &wecom{
+		webhook: webhook,
+		mention: mention,
+	}
+}
+
+func (n *wecom) Name() string {
+	return typeWeCom
+}
+
+type robotResp struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (n *wecom) Notify(msg *Message) error {
+	if msg.empty() {
+		return nil
+	}
+	texts := markdown(msg, wecomMessageLimit)
+	count := len(texts)
+	if msg.Priority && (len(n.mention.Users) != 0 || n.mention.All) {
+		// markdown 消息不支持 @ 成员，最后另外发送一条文本消息
+		count++
+	}
+	return msg.sendParts(n, count, func(i int) error {
+		if i < len(texts) {
+			return n.send(map[string]interface{}{
+				"msgtype": "markdown",
+				"markdown": map[string]string{
+					"content": texts[i],
+				},
+			})
+		}
+		mobiles := append([]string{}, n.mention.Users...)
+		if n.mention.All {
+			mobiles = append(mobiles, "@all")
+		}
+		return n.send(map[string]interface{}{
+			"msgtype": "text",
+			"text": map[string]interface{}{
+				"content":               msg.Title,
+				"mentioned_mobile_list": mobiles,
+			},
+		})
+	})
+}
+
+func (n *wecom) send(req map[string]interface{}) error {
+	var resp robotResp
+	if err := postJSON(n.webhook, req, &resp); err != nil {
+		return err
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("企业微信机器人返回错误 %d %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
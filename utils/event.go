@@ -1,12 +1,72 @@
 package utils
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Event 活动信息，各来源平台、通知渠道以及对外的 JSON 接口都使用该结构
 type Event struct {
-	Name       string
-	WebURL     string
-	WebViewURL string
-	Cover      string
-	Time       string
-	Artist     string
-	Site       string
-	Price      string
+	// Source 活动来源平台，如 showstart、simullink、zhengzai
+	Source string `json:"source"`
+	// ID 活动在来源平台上的唯一ID
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	WebURL     string `json:"web_url,omitempty"`
+	WebViewURL string `json:"web_view_url,omitempty"`
+	// Cover 封面图片链接
+	Cover string `json:"cover,omitempty"`
+	// CoverFile 保存到本地的封面图片路径
+	CoverFile string `json:"-"`
+	// Time 平台上显示的演出时间，Start 和 End 为解析后的开始和结束时间（北京时间），解析不到时为零值
+	Time   string    `json:"time"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Artist string    `json:"artist"`
+	Site   string    `json:"site"`
+	City   string    `json:"city,omitempty"`
+	Price  string    `json:"price"`
+	// Tags 活动的标签，如 摇滚、民谣
+	Tags []string `json:"tags,omitempty"`
+	// SellTime 开售时间，SellMemberTime 会员开售时间，StopSellTime 停售时间，平台没有提供时为零值
+	SellTime       time.Time `json:"sell_time"`
+	SellMemberTime time.Time `json:"sell_member_time"`
+	StopSellTime   time.Time `json:"stop_sell_time"`
+}
+
+// Artists 将平台上显示的艺人拆分为单个艺人，平台通常用顿号、逗号、斜杠等分隔多个艺人
+func (e *Event) Artists() []string {
+	parts := strings.FieldsFunc(e.Artist, func(r rune) bool {
+		return strings.ContainsRune("、,，/／&＆|｜;；\n", r)
+	})
+	artists := make([]string, 0, len(parts))
+	seen := make(map[string]bool)
+	for _, a := range parts {
+		a = strings.TrimSpace(a)
+		if a == "" || seen[a] {
+			continue
+		}
+		seen[a] = true
+		artists = append(artists, a)
+	}
+	return artists
+}
+
+// Key 活动在数据库中的键，由来源平台和平台内的活动ID组成，保证跨平台唯一且稳定
+func (e *Event) Key() string {
+	return EventKey(e.Source, e.ID)
+}
+
+func EventKey(source, id string) string {
+	return fmt.Sprintf("%s_eventid_%s", source, id)
+}
+
+// SplitKey 从活动在数据库中的键解析出来源平台和活动ID
+func SplitKey(key string) (source, id string, ok bool) {
+	parts := strings.SplitN(key, "_eventid_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
 }
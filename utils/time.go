@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Location 所有平台的时间都按北京时间解析
+var Location = loadLocation()
+
+func loadLocation() *time.Location {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return time.FixedZone("CST", 8*60*60)
+	}
+	return loc
+}
+
+var dateRegexp = regexp.MustCompile(`(\d{4})[-./年](\d{1,2})[-./月](\d{1,2})`)
+
+// Date 返回演出日期，优先使用解析好的开始时间，没有时从演出时间的文本中解析
+func (e *Event) Date() (time.Time, bool) {
+	if !e.Start.IsZero() {
+		start := e.Start.In(Location)
+		return time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, Location), true
+	}
+	m := dateRegexp.FindStringSubmatch(e.Time)
+	if len(m) != 4 {
+		return time.Time{}, false
+	}
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, Location), true
+}
+
+// ParseClock 解析一天中的时间，如 09:00，返回距离零点的时间
+func ParseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// timeTokenRegexp 依次匹配演出时间中的 年月日、月日、时分
+var timeTokenRegexp = regexp.MustCompile(`(\d{4})[-./年](\d{1,2})[-./月](\d{1,2})日?|(\d{1,2})[-./月](\d{1,2})日?|(\d{1,2})[:：](\d{2})`)
+
+// entryTimeRegexp 入场、检票时间，如 (19:30入场)、19:30进场，不是演出的开始或结束时间
+var entryTimeRegexp = regexp.MustCompile(`[(（][^()（）]*(?:入场|进场|检票)[^()（）]*[)）]|\d{1,2}[:：]\d{2}\s*(?:入场|进场|检票)`)
+
+// ParseEventTime 解析演出时间的文本，返回开始和结束时间，解析不到时为零值。支持的格式如：
+// 2024.03.02 周六 20:00、2024年3月2日 20:00-22:00、2024.05.01-05.03、2024.05.01 13:00 - 2024.05.03 22:00、周六 20:00。
+// 只有星期时为今天及之后最近的那一天，没有年份时使用最近的将来的年份，多日的活动没有结束时间时结束于最后一天的 24 点，
+// 入场时间如 2024.03.02 20:00 (19:30入场) 中的 19:30 不参与解析
+func ParseEventTime(s string) (start, end time.Time) {
+	s = entryTimeRegexp.ReplaceAllString(s, " ")
+	var dates []time.Time
+	// clocks 每个日期之后出现的第一个时间，第一个日期之后的第二个时间为同一天的结束时间
+	var clocks [][]time.Duration
+	// noDate 出现在所有日期之前的时间，只有星期时使用
+	var noDate []time.Duration
+	for _, m := range timeTokenRegexp.FindAllStringSubmatch(s, -1) {
+		v := make([]int, len(m))
+		for i := 1; i < len(m); i++ {
+			v[i], _ = strconv.Atoi(m[i])
+		}
+		switch {
+		case m[1] != "":
+			dates = append(dates, time.Date(v[1], time.Month(v[2]), v[3], 0, 0, 0, 0, Location))
+			clocks = append(clocks, nil)
+		case m[4] != "":
+			dates = append(dates, time.Date(yearOf(dates, v[4], v[5]), time.Month(v[4]), v[5], 0, 0, 0, 0, Location))
+			clocks = append(clocks, nil)
+		case len(dates) != 0:
+			last := len(clocks) - 1
+			clocks[last] = append(clocks[last], time.Duration(v[6])*time.Hour+time.Duration(v[7])*time.Minute)
+		default:
+			noDate = append(noDate, time.Duration(v[6])*time.Hour+time.Duration(v[7])*time.Minute)
+		}
+	}
+	if len(dates) == 0 {
+		date, ok := nextWeekday(s)
+		if !ok {
+			return time.Time{}, time.Time{}
+		}
+		dates = []time.Time{date}
+		clocks = [][]time.Duration{noDate}
+	}
+	start = dates[0]
+	if len(clocks[0]) != 0 {
+		start = start.Add(clocks[0][0])
+	}
+	last := len(dates) - 1
+	switch {
+	case last > 0 && len(clocks[last]) != 0:
+		end = dates[last].Add(clocks[last][0])
+	case last > 0:
+		end = dates[last].AddDate(0, 0, 1)
+	case len(clocks[0]) > 1:
+		end = dates[0].Add(clocks[0][1])
+		if end.Before(start) {
+			// 如 20:00-02:00，结束于第二天
+			end = end.AddDate(0, 0, 1)
+		}
+	}
+	return start, end
+}
+
+// yearOf 推断没有年份的日期的年份，有前一个日期时为它之后最近的年份，否则为今天之后最近的年份
+func yearOf(dates []time.Time, month, day int) int {
+	after := time.Now().In(Location).AddDate(0, 0, -1)
+	if len(dates) != 0 {
+		after = dates[len(dates)-1]
+	}
+	year := after.Year()
+	if time.Date(year, time.Month(month), day, 0, 0, 0, 0, Location).Before(after) {
+		year++
+	}
+	return year
+}
+
+var weekdayRegexp = regexp.MustCompile(`(?:周|星期|礼拜)([日天一二三四五六])`)
+
+// nextWeekday 返回文本中的星期在今天及之后最近的日期
+func nextWeekday(s string) (time.Time, bool) {
+	m := weekdayRegexp.FindStringSubmatch(s)
+	if len(m) != 2 {
+		return time.Time{}, false
+	}
+	weekday := time.Weekday(strings.Index("日一二三四五六", strings.Replace(m[1], "天", "日", 1)) / len("日"))
+	now := time.Now().In(Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, Location)
+	return today.AddDate(0, 0, (int(weekday)-int(now.Weekday())+7)%7), true
+}
+
+// SortByStart 按演出开始时间排序，没有开始时间的活动排在最后并保持原来的顺序
+func SortByStart(events []*Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i].Start, events[j].Start
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEventTime(t *testing.T) {
+	at := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, Location)
+	}
+	tests := []struct {
+		s          string
+		start, end time.Time
+	}{
+		// 秀动
+		{"2024.03.02 周六 20:00", at(2024, 3, 2, 20, 0), time.Time{}},
+		{"2024.03.02 20:00 (19:30入场)", at(2024, 3, 2, 20, 0), time.Time{}},
+		{"2024.03.02 周六 20:00（19:00进场）", at(2024, 3, 2, 20, 0), time.Time{}},
+		{"2024.03.02 19:30检票 20:00", at(2024, 3, 2, 20, 0), time.Time{}},
+		{"2024.03.02 20:00-22:00", at(2024, 3, 2, 20, 0), at(2024, 3, 2, 22, 0)},
+		{"2024.03.02 22:00-02:00", at(2024, 3, 2, 22, 0), at(2024, 3, 3, 2, 0)},
+		{"2024.05.01-05.03", at(2024, 5, 1, 0, 0), at(2024, 5, 4, 0, 0)},
+		{"2024.12.31-01.01", at(2024, 12, 31, 0, 0), at(2025, 1, 2, 0, 0)},
+		{"2024.05.01 13:00 - 2024.05.03 22:00", at(2024, 5, 1, 13, 0), at(2024, 5, 3, 22, 0)},
+		{"2024.05.01 13:00 - 05.03 22:00", at(2024, 5, 1, 13, 0), at(2024, 5, 3, 22, 0)},
+		// 其他常见格式
+		{"2024年3月2日 20:00-22:00", at(2024, 3, 2, 20, 0), at(2024, 3, 2, 22, 0)},
+		{"2024-03-02 20:00", at(2024, 3, 2, 20, 0), time.Time{}},
+		{"2024/3/2", at(2024, 3, 2, 0, 0), time.Time{}},
+		{"2024.03.02 20：00", at(2024, 3, 2, 20, 0), time.Time{}},
+		// 解析不到
+		{"", time.Time{}, time.Time{}},
+		{"待定", time.Time{}, time.Time{}},
+		{"20:00", time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		start, end := ParseEventTime(tt.s)
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("ParseEventTime(%q) = %v, %v，应为 %v, %v", tt.s, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestParseEventTimeRelative(t *testing.T) {
+	now := time.Now().In(Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, Location)
+
+	// 只有星期时为今天及之后最近的那一天
+	start, end := ParseEventTime("周六 20:00")
+	if start.Weekday() != time.Saturday || start.Hour() != 20 || start.Before(today) || !start.Before(today.AddDate(0, 0, 7)) || !end.IsZero() {
+		t.Errorf("ParseEventTime(周六 20:00) = %v, %v", start, end)
+	}
+
+	// 没有年份时使用最近的将来的年份
+	tomorrow := today.AddDate(0, 0, 1)
+	start, _ = ParseEventTime(tomorrow.Format("01.02 15:04"))
+	if !start.Equal(tomorrow) {
+		t.Errorf("没有年份的明天解析为 %v，应为 %v", start, tomorrow)
+	}
+	lastWeek := today.AddDate(0, 0, -7)
+	start, _ = ParseEventTime(lastWeek.Format("01月02日"))
+	if want := lastWeek.AddDate(1, 0, 0); !start.Equal(want) {
+		t.Errorf("没有年份的上周解析为 %v，应为 %v", start, want)
+	}
+}
+
+func TestDate(t *testing.T) {
+	tests := []struct {
+		e    Event
+		want time.Time
+		ok   bool
+	}{
+		{Event{Start: time.Date(2024, 3, 2, 15, 0, 0, 0, time.UTC)}, time.Date(2024, 3, 2, 0, 0, 0, 0, Location), true},
+		{Event{Start: time.Date(2024, 3, 2, 17, 0, 0, 0, time.UTC)}, time.Date(2024, 3, 3, 0, 0, 0, 0, Location), true},
+		{Event{Time: "2024年3月2日 20:00"}, time.Date(2024, 3, 2, 0, 0, 0, 0, Location), true},
+		{Event{Time: "周六 20:00"}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.e.Date()
+		if !got.Equal(tt.want) || ok != tt.ok {
+			t.Errorf("%+v 的日期为 %v, %v，应为 %v, %v", tt.e, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	if d, err := ParseClock("09:30"); err != nil || d != 9*time.Hour+30*time.Minute {
+		t.Errorf("ParseClock(09:30) = %v, %v", d, err)
+	}
+	if _, err := ParseClock("9点"); err == nil {
+		t.Error("ParseClock(9点) 应返回错误")
+	}
+}